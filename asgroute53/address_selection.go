@@ -0,0 +1,167 @@
+package asgroute53
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// Address sources selectable with the address-source tag
+const (
+	AddressSourcePrimary    = "primary"
+	AddressSourceSecondary  = "secondary"
+	AddressSourceElasticIP  = "elastic-ip"
+	AddressSourceAllPrivate = "all-private"
+)
+
+// amazonIPOwnerID owns public IPs auto-assigned by EC2, any other owner means an Elastic IP
+const amazonIPOwnerID = "amazon"
+
+// SelectsNetworkInterface returns true if addresses are taken from a network interface selected
+// by device index or tag instead of the primary one
+func (c *Route53ZoneConfig) SelectsNetworkInterface() bool {
+	return c.NetworkInterfaceIndex != nil || c.NetworkInterfaceTag != nil
+}
+
+// ResolveNetworkInterface sets NetworkInterfaceID to the network interface of the instance that
+// carries NetworkInterfaceTag. EC2 only returns interface tags from DescribeNetworkInterfaces, so
+// the caller describes the interfaces of the instance.
+func (c *Route53ZoneConfig) ResolveNetworkInterface(networkInterfaces []*ec2.NetworkInterface) error {
+	if c.NetworkInterfaceTag == nil {
+		return nil
+	}
+
+	for _, networkInterface := range networkInterfaces {
+		for _, tag := range networkInterface.TagSet {
+			if aws.StringValue(tag.Key) == aws.StringValue(c.NetworkInterfaceTag.Key) &&
+				aws.StringValue(tag.Value) == aws.StringValue(c.NetworkInterfaceTag.Value) {
+				c.NetworkInterfaceID = networkInterface.NetworkInterfaceId
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("no network interface tagged %s=%s",
+		aws.StringValue(c.NetworkInterfaceTag.Key), aws.StringValue(c.NetworkInterfaceTag.Value))
+}
+
+// selectNetworkInterfaces returns the network interfaces whose addresses are put in the records
+func (c *Route53ZoneConfig) selectNetworkInterfaces(ec2Instance *ec2.Instance) ([]*ec2.InstanceNetworkInterface, error) {
+	instanceID := aws.StringValue(ec2Instance.InstanceId)
+
+	switch {
+	case c.NetworkInterfaceTag != nil:
+		if c.NetworkInterfaceID == nil {
+			return nil, fmt.Errorf("network interface tagged %s=%s was not resolved",
+				aws.StringValue(c.NetworkInterfaceTag.Key), aws.StringValue(c.NetworkInterfaceTag.Value))
+		}
+		for _, networkInterface := range ec2Instance.NetworkInterfaces {
+			if aws.StringValue(networkInterface.NetworkInterfaceId) == *c.NetworkInterfaceID {
+				return []*ec2.InstanceNetworkInterface{networkInterface}, nil
+			}
+		}
+		return nil, fmt.Errorf("network interface %s is not attached to instance %s", *c.NetworkInterfaceID, instanceID)
+	case c.NetworkInterfaceIndex != nil:
+		for _, networkInterface := range ec2Instance.NetworkInterfaces {
+			if networkInterface.Attachment != nil && aws.Int64Value(networkInterface.Attachment.DeviceIndex) == *c.NetworkInterfaceIndex {
+				return []*ec2.InstanceNetworkInterface{networkInterface}, nil
+			}
+		}
+		return nil, fmt.Errorf("instance %s has no network interface at device index %d", instanceID, *c.NetworkInterfaceIndex)
+	case c.AddressSource == AddressSourceAllPrivate:
+		return ec2Instance.NetworkInterfaces, nil
+	}
+
+	var primary []*ec2.InstanceNetworkInterface
+	for _, networkInterface := range ec2Instance.NetworkInterfaces {
+		if networkInterface.Attachment == nil || aws.Int64Value(networkInterface.Attachment.DeviceIndex) == 0 {
+			primary = append(primary, networkInterface)
+		}
+	}
+
+	return primary, nil
+}
+
+// getIPv4Addresses returns the IPv4 addresses selected by the address source. Records of public
+// zones get the public IPs associated with the selected private IPs.
+func getIPv4Addresses(config *Route53ZoneConfig, ec2Instance *ec2.Instance) ([]*string, error) {
+	instanceID := aws.StringValue(ec2Instance.InstanceId)
+	source := config.AddressSource
+	if source == "" {
+		source = AddressSourcePrimary
+	}
+
+	if source == AddressSourcePrimary && !config.SelectsNetworkInterface() {
+		if !config.IsPublic {
+			return []*string{ec2Instance.PrivateIpAddress}, nil
+		}
+		if ec2Instance.PublicIpAddress == nil {
+			return nil, fmt.Errorf("instance %s has no public IPv4 address", instanceID)
+		}
+		return []*string{ec2Instance.PublicIpAddress}, nil
+	}
+
+	networkInterfaces, err := config.selectNetworkInterfaces(ec2Instance)
+	if err != nil {
+		return nil, err
+	}
+
+	var addresses []*string
+	for _, networkInterface := range networkInterfaces {
+		for _, privateIPAddress := range networkInterface.PrivateIpAddresses {
+			association := privateIPAddress.Association
+			switch source {
+			case AddressSourceAllPrivate:
+				addresses = append(addresses, privateIPAddress.PrivateIpAddress)
+				continue
+			case AddressSourceElasticIP:
+				if association != nil && aws.StringValue(association.IpOwnerId) != amazonIPOwnerID {
+					addresses = append(addresses, association.PublicIp)
+				}
+				continue
+			case AddressSourceSecondary:
+				if aws.BoolValue(privateIPAddress.Primary) {
+					continue
+				}
+			default:
+				if !aws.BoolValue(privateIPAddress.Primary) {
+					continue
+				}
+			}
+
+			if !config.IsPublic {
+				addresses = append(addresses, privateIPAddress.PrivateIpAddress)
+			} else if association != nil && association.PublicIp != nil {
+				addresses = append(addresses, association.PublicIp)
+			}
+		}
+	}
+
+	if len(addresses) == 0 {
+		return nil, fmt.Errorf("instance %s has no %s IPv4 address on the selected network interfaces", instanceID, source)
+	}
+
+	return addresses, nil
+}
+
+// getIPv6Addresses returns IPv6 addresses assigned to the selected network interfaces
+func getIPv6Addresses(config *Route53ZoneConfig, ec2Instance *ec2.Instance) ([]*string, error) {
+	networkInterfaces, err := config.selectNetworkInterfaces(ec2Instance)
+	if err != nil {
+		return nil, err
+	}
+
+	var ipv6Addresses []*string
+	for _, networkInterface := range networkInterfaces {
+		for _, ipv6Address := range networkInterface.Ipv6Addresses {
+			ipv6Addresses = append(ipv6Addresses, ipv6Address.Ipv6Address)
+		}
+	}
+
+	if len(ipv6Addresses) == 0 {
+		return nil, fmt.Errorf("instance %s has no IPv6 address", aws.StringValue(ec2Instance.InstanceId))
+	}
+
+	return ipv6Addresses, nil
+}
@@ -0,0 +1,171 @@
+package asgroute53
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+func Test_getIPv4Addresses(t *testing.T) {
+	ec2Instance := &ec2.Instance{
+		InstanceId:       aws.String("i-1"),
+		PrivateIpAddress: aws.String("10.0.0.1"),
+		PublicIpAddress:  aws.String("203.0.113.1"),
+		NetworkInterfaces: []*ec2.InstanceNetworkInterface{
+			{
+				NetworkInterfaceId: aws.String("eni-0"),
+				Attachment:         &ec2.InstanceNetworkInterfaceAttachment{DeviceIndex: aws.Int64(0)},
+				PrivateIpAddresses: []*ec2.InstancePrivateIpAddress{
+					{
+						Primary:          aws.Bool(true),
+						PrivateIpAddress: aws.String("10.0.0.1"),
+						Association: &ec2.InstanceNetworkInterfaceAssociation{
+							IpOwnerId: aws.String(amazonIPOwnerID),
+							PublicIp:  aws.String("203.0.113.1"),
+						},
+					},
+				},
+			},
+			{
+				NetworkInterfaceId: aws.String("eni-1"),
+				Attachment:         &ec2.InstanceNetworkInterfaceAttachment{DeviceIndex: aws.Int64(1)},
+				PrivateIpAddresses: []*ec2.InstancePrivateIpAddress{
+					{
+						Primary:          aws.Bool(true),
+						PrivateIpAddress: aws.String("10.0.1.1"),
+					},
+					{
+						Primary:          aws.Bool(false),
+						PrivateIpAddress: aws.String("10.0.1.2"),
+						Association: &ec2.InstanceNetworkInterfaceAssociation{
+							IpOwnerId: aws.String("123456789012"),
+							PublicIp:  aws.String("198.51.100.1"),
+						},
+					},
+				},
+			},
+		},
+	}
+	tests := []struct {
+		name    string
+		config  *Route53ZoneConfig
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:    "primary",
+			config:  &Route53ZoneConfig{},
+			want:    []string{"10.0.0.1"},
+			wantErr: false,
+		},
+		{
+			name:    "primary-public",
+			config:  &Route53ZoneConfig{IsPublic: true},
+			want:    []string{"203.0.113.1"},
+			wantErr: false,
+		},
+		{
+			name: "device-index",
+			config: &Route53ZoneConfig{
+				AddressSource:         AddressSourcePrimary,
+				NetworkInterfaceIndex: aws.Int64(1),
+			},
+			want:    []string{"10.0.1.1"},
+			wantErr: false,
+		},
+		{
+			name: "interface-tag-secondary",
+			config: &Route53ZoneConfig{
+				AddressSource:       AddressSourceSecondary,
+				NetworkInterfaceTag: &ec2.Tag{Key: aws.String("role"), Value: aws.String("data")},
+				NetworkInterfaceID:  aws.String("eni-1"),
+			},
+			want:    []string{"10.0.1.2"},
+			wantErr: false,
+		},
+		{
+			name: "elastic-ip",
+			config: &Route53ZoneConfig{
+				AddressSource:         AddressSourceElasticIP,
+				NetworkInterfaceIndex: aws.Int64(1),
+			},
+			want:    []string{"198.51.100.1"},
+			wantErr: false,
+		},
+		{
+			name: "elastic-ip-missing",
+			config: &Route53ZoneConfig{
+				AddressSource: AddressSourceElasticIP,
+			},
+			wantErr: true,
+		},
+		{
+			name: "all-private",
+			config: &Route53ZoneConfig{
+				AddressSource: AddressSourceAllPrivate,
+			},
+			want:    []string{"10.0.0.1", "10.0.1.1", "10.0.1.2"},
+			wantErr: false,
+		},
+		{
+			name: "device-index-missing",
+			config: &Route53ZoneConfig{
+				AddressSource:         AddressSourcePrimary,
+				NetworkInterfaceIndex: aws.Int64(2),
+			},
+			wantErr: true,
+		},
+		{
+			name: "interface-tag-unresolved",
+			config: &Route53ZoneConfig{
+				AddressSource:       AddressSourcePrimary,
+				NetworkInterfaceTag: &ec2.Tag{Key: aws.String("role"), Value: aws.String("data")},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := getIPv4Addresses(tt.config, ec2Instance)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("getIPv4Addresses() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(aws.StringValueSlice(got), tt.want) {
+				t.Errorf("getIPv4Addresses() = %v, want %v", aws.StringValueSlice(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestRoute53ZoneConfig_ResolveNetworkInterface(t *testing.T) {
+	networkInterfaces := []*ec2.NetworkInterface{
+		{
+			NetworkInterfaceId: aws.String("eni-0"),
+		},
+		{
+			NetworkInterfaceId: aws.String("eni-1"),
+			TagSet:             []*ec2.Tag{{Key: aws.String("role"), Value: aws.String("data")}},
+		},
+	}
+
+	config := &Route53ZoneConfig{
+		NetworkInterfaceTag: &ec2.Tag{Key: aws.String("role"), Value: aws.String("data")},
+	}
+	if err := config.ResolveNetworkInterface(networkInterfaces); err != nil {
+		t.Fatalf("Route53ZoneConfig.ResolveNetworkInterface() error = %v", err)
+	}
+	if got := aws.StringValue(config.NetworkInterfaceID); got != "eni-1" {
+		t.Errorf("Route53ZoneConfig.NetworkInterfaceID = %v, want eni-1", got)
+	}
+
+	config.NetworkInterfaceTag = &ec2.Tag{Key: aws.String("role"), Value: aws.String("web")}
+	if err := config.ResolveNetworkInterface(networkInterfaces); err == nil {
+		t.Errorf("Route53ZoneConfig.ResolveNetworkInterface() error = nil, want error")
+	}
+}
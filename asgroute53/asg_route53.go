@@ -1,7 +1,10 @@
 package asgroute53
 
 import (
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
@@ -9,68 +12,206 @@ import (
 	"github.com/aws/aws-sdk-go/service/route53/route53iface"
 )
 
-const ttl = 10
+// defaultTTL is the TTL of records when none is configured
+const defaultTTL = 10
+
+var errRecordSetNotFound = errors.New("could not find record or SetIdentifier did not match")
 
 // ASGRoute53 handles updating and deleting DNS record for EC2 instances in an ASG
 type ASGRoute53 struct {
-	route53Client route53iface.Route53API
+	route53Client     route53iface.Route53API
+	pollInterval      time.Duration
+	heartbeatInterval time.Duration
+	// conflictRetryDelay bounds the random delay before the first retry of a conflicting update
+	conflictRetryDelay time.Duration
 }
 
 // New creates new instance of asgRoute53
 func New(route53Client route53iface.Route53API) *ASGRoute53 {
 	return &ASGRoute53{
-		route53Client: route53Client,
+		route53Client:      route53Client,
+		pollInterval:       5 * time.Second,
+		heartbeatInterval:  HeartbeatInterval,
+		conflictRetryDelay: baseRetryDelay,
+	}
+}
+
+// DeleteRecordSets deletes record set from hosted zone. Records whose TXT companion record is not
+// owned by the instance are left untouched. It returns the IDs of the Route 53 changes.
+func (r *ASGRoute53) DeleteRecordSets(config *Route53ZoneConfig, ec2Instance *ec2.Instance) ([]string, error) {
+	batch := r.NewChangeBatch()
+	if err := batch.DeleteRecordSets("", config, ec2Instance); err != nil {
+		return nil, err
+	}
+
+	changeIDs, errs := batch.Submit()
+	return changeIDs[""], errs[""]
+}
+
+// UpsertRecordSets creates DNS record for an EC2 instance. It returns the IDs of the Route 53 changes.
+func (r *ASGRoute53) UpsertRecordSets(config *Route53ZoneConfig, ec2Instance *ec2.Instance) ([]string, error) {
+	batch := r.NewChangeBatch()
+	if err := batch.UpsertRecordSets("", config, ec2Instance); err != nil {
+		return nil, err
 	}
+
+	changeIDs, errs := batch.Submit()
+	return changeIDs[""], errs[""]
 }
 
-// DeleteRecordSets deletes record set from hosted zone
-func (r *ASGRoute53) DeleteRecordSets(config *Route53ZoneConfig, ec2Instance *ec2.Instance) error {
-	var changes []*route53.Change
+// getDeleteChangeGroup builds the changes deleting the records owned by the instance. Record sets
+// are deleted exactly as stored, including the TTL and health check they currently have.
+func (r *ASGRoute53) getDeleteChangeGroup(config *Route53ZoneConfig, ec2Instance *ec2.Instance) (*changeGroup, error) {
+	instanceID := *ec2Instance.InstanceId
+	group := &changeGroup{
+		hostedZoneID: config.HostedZoneID,
+	}
+
 	for _, record := range config.DNSRecords {
-		recordSet, err := r.getARecordSet(config.HostedZoneID, record, config.SetIdentifier)
+		txtRecordSet, err := r.getOwnedTXTRecordSet(config, record, instanceID)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		if txtRecordSet == nil {
+			fmt.Printf("Skipping %s, TXT record is not owned by %s\n", record, instanceID)
+			continue
 		}
 
-		newChanges := r.getChanges("DELETE", config, record, ttl, *ec2Instance.InstanceId, recordSet.ResourceRecords)
-		changes = append(changes, newChanges...)
+		group.ownedRecords = append(group.ownedRecords, record)
+		group.changes = append(group.changes, &route53.Change{
+			Action:            aws.String("DELETE"),
+			ResourceRecordSet: txtRecordSet,
+		})
+
+		for _, recordType := range config.RecordTypes() {
+			recordSet, err := r.getRecordSet(config.HostedZoneID, record, recordType, config.SetIdentifier)
+			if err != nil {
+				return nil, err
+			}
+
+			group.changes = append(group.changes, &route53.Change{
+				Action:            aws.String("DELETE"),
+				ResourceRecordSet: recordSet,
+			})
+
+			healthCheckID := recordSet.HealthCheckId
+			if config.HealthCheck != nil && healthCheckID != nil && !containsValue(group.obsoleteHealthCheckIDs, *healthCheckID) {
+				group.obsoleteHealthCheckIDs = append(group.obsoleteHealthCheckIDs, *healthCheckID)
+			}
+		}
 	}
 
-	_, err := r.route53Client.ChangeResourceRecordSets(&route53.ChangeResourceRecordSetsInput{
-		ChangeBatch: &route53.ChangeBatch{
-			Changes: changes,
-		},
-		HostedZoneId: &config.HostedZoneID,
-	})
+	return group, nil
+}
+
+// getOwnedTXTRecordSet returns the TXT companion record of name if it lists the instance as its
+// owner, or nil otherwise
+func (r *ASGRoute53) getOwnedTXTRecordSet(config *Route53ZoneConfig, name string, instanceID string) (*route53.ResourceRecordSet, error) {
+	recordSet, err := r.getRecordSet(config.HostedZoneID, config.ownerRecordName(name), "TXT", config.SetIdentifier)
+	if errors.Is(err, errRecordSetNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, owner := range getOwners(recordSet) {
+		if owner == instanceID {
+			return recordSet, nil
+		}
+	}
 
-	return err
+	return nil, nil
 }
 
-// UpsertRecordSets creates DNS record for an EC2 instance
-func (r *ASGRoute53) UpsertRecordSets(config *Route53ZoneConfig, ec2Instance *ec2.Instance) error {
-	ipAddress := ec2Instance.PrivateIpAddress
-	if config.IsPublic {
-		ipAddress = ec2Instance.PublicIpAddress
+// getUpsertChangeGroup builds the changes registering the instance, creating its health check if configured
+func (r *ASGRoute53) getUpsertChangeGroup(config *Route53ZoneConfig, ec2Instance *ec2.Instance) (*changeGroup, error) {
+	resourceRecords, err := r.getResourceRecords(config, ec2Instance)
+	if err != nil {
+		return nil, err
+	}
+
+	group := &changeGroup{
+		hostedZoneID: config.HostedZoneID,
+	}
+
+	var healthCheckID *string
+	if config.HealthCheck != nil {
+		addresses := resourceRecords[config.RecordTypes()[0]]
+		if len(addresses) != 1 {
+			return nil, fmt.Errorf("a health check watches one address, but the records of %s hold %d",
+				*ec2Instance.InstanceId, len(addresses))
+		}
+
+		// Health checks of the record sets being overwritten, e.g. by a redelivered launch, are deleted once replaced
+		group.obsoleteHealthCheckIDs, err = r.getHealthCheckIDs(config)
+		if err != nil {
+			return nil, err
+		}
+
+		healthCheckID, err = r.createHealthCheck(config, *ec2Instance.InstanceId, addresses[0].Value)
+		if err != nil {
+			return nil, err
+		}
+		group.createdHealthCheckIDs = append(group.createdHealthCheckIDs, *healthCheckID)
 	}
 
-	var changes []*route53.Change
 	for _, record := range config.DNSRecords {
-		newChanges := r.getChanges("UPSERT", config, record, ttl, *ec2Instance.InstanceId, []*route53.ResourceRecord{
-			{
-				Value: ipAddress,
-			},
-		})
-		changes = append(changes, newChanges...)
+		newChanges := r.getChanges("UPSERT", config, record, config.TTLFor(record), *ec2Instance.InstanceId, resourceRecords, healthCheckID)
+		group.changes = append(group.changes, newChanges...)
 	}
 
-	_, err := r.route53Client.ChangeResourceRecordSets(&route53.ChangeResourceRecordSetsInput{
-		ChangeBatch: &route53.ChangeBatch{
-			Changes: changes,
-		},
-		HostedZoneId: aws.String(config.HostedZoneID),
-	})
+	return group, nil
+}
 
-	return err
+// getHealthCheckIDs returns the health checks attached to the current record sets of the configuration
+func (r *ASGRoute53) getHealthCheckIDs(config *Route53ZoneConfig) ([]string, error) {
+	var healthCheckIDs []string
+	for _, record := range config.DNSRecords {
+		for _, recordType := range config.RecordTypes() {
+			recordSet, err := r.getRecordSet(config.HostedZoneID, record, recordType, config.SetIdentifier)
+			if errors.Is(err, errRecordSetNotFound) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			healthCheckID := recordSet.HealthCheckId
+			if healthCheckID != nil && !containsValue(healthCheckIDs, *healthCheckID) {
+				healthCheckIDs = append(healthCheckIDs, *healthCheckID)
+			}
+		}
+	}
+
+	return healthCheckIDs, nil
+}
+
+func (r *ASGRoute53) getResourceRecords(config *Route53ZoneConfig, ec2Instance *ec2.Instance) (map[string][]*route53.ResourceRecord, error) {
+	resourceRecords := map[string][]*route53.ResourceRecord{}
+	for _, recordType := range config.RecordTypes() {
+		var values []*string
+		var err error
+		switch recordType {
+		case "A":
+			values, err = getIPv4Addresses(config, ec2Instance)
+		case "AAAA":
+			values, err = getIPv6Addresses(config, ec2Instance)
+		case "CNAME":
+			values, err = getDNSName(config, ec2Instance)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, address := range values {
+			resourceRecords[recordType] = append(resourceRecords[recordType], &route53.ResourceRecord{
+				Value: address,
+			})
+		}
+	}
+
+	return resourceRecords, nil
 }
 
 func (r *ASGRoute53) getChanges(action string,
@@ -78,12 +219,13 @@ func (r *ASGRoute53) getChanges(action string,
 	name string,
 	ttl int64,
 	instanceID string,
-	aResourceRecords []*route53.ResourceRecord) []*route53.Change {
-	return []*route53.Change{
+	resourceRecords map[string][]*route53.ResourceRecord,
+	healthCheckID *string) []*route53.Change {
+	changes := []*route53.Change{
 		{
 			Action: aws.String(action),
 			ResourceRecordSet: &route53.ResourceRecordSet{
-				Name: aws.String(name),
+				Name: aws.String(config.ownerRecordName(name)),
 				Type: aws.String("TXT"),
 				ResourceRecords: []*route53.ResourceRecord{
 					{
@@ -93,40 +235,107 @@ func (r *ASGRoute53) getChanges(action string,
 				TTL:              aws.Int64(ttl),
 				SetIdentifier:    config.SetIdentifier,
 				MultiValueAnswer: config.MultiValueAnswer(),
+				Weight:           config.Weight,
+				Region:           config.Region,
+				GeoLocation:      config.GeoLocation,
+				Failover:         config.Failover,
 			},
 		},
-		{
+	}
+
+	for _, recordType := range config.RecordTypes() {
+		changes = append(changes, &route53.Change{
 			Action: aws.String(action),
 			ResourceRecordSet: &route53.ResourceRecordSet{
 				Name:             aws.String(name),
-				Type:             aws.String("A"),
-				ResourceRecords:  aResourceRecords,
+				Type:             aws.String(recordType),
+				ResourceRecords:  resourceRecords[recordType],
 				TTL:              aws.Int64(ttl),
 				SetIdentifier:    config.SetIdentifier,
 				MultiValueAnswer: config.MultiValueAnswer(),
+				Weight:           config.Weight,
+				Region:           config.Region,
+				GeoLocation:      config.GeoLocation,
+				Failover:         config.Failover,
+				HealthCheckId:    healthCheckID,
 			},
-		},
+		})
 	}
+
+	return changes
 }
 
-func (r *ASGRoute53) getARecordSet(hostedZoneID string, name string, setIdentifier *string) (*route53.ResourceRecordSet, error) {
-	recordOutput, err := r.route53Client.ListResourceRecordSets(&route53.ListResourceRecordSetsInput{
+// getRecordSet finds the record set that exactly matches name, type and set identifier, following
+// pagination until the listing walks past the requested name and type
+func (r *ASGRoute53) getRecordSet(hostedZoneID string, name string, recordType string, setIdentifier *string) (*route53.ResourceRecordSet, error) {
+	input := &route53.ListResourceRecordSetsInput{
 		HostedZoneId:    aws.String(hostedZoneID),
-		StartRecordType: aws.String("A"),
+		StartRecordType: aws.String(recordType),
 		StartRecordName: aws.String(name),
-	})
-
-	if err != nil {
-		return nil, err
 	}
+	normalizedName := normalizeRecordName(name)
 
-	for _, recordSet := range recordOutput.ResourceRecordSets {
-		if setIdentifier == nil && recordSet.SetIdentifier == nil {
-			return recordSet, nil
-		} else if setIdentifier != nil && recordSet.SetIdentifier != nil && *setIdentifier == *recordSet.SetIdentifier {
-			return recordSet, nil
+	for {
+		recordOutput, err := r.route53Client.ListResourceRecordSets(input)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, recordSet := range recordOutput.ResourceRecordSets {
+			if normalizeRecordName(aws.StringValue(recordSet.Name)) != normalizedName ||
+				aws.StringValue(recordSet.Type) != recordType {
+				return nil, fmt.Errorf("%w: %s %s", errRecordSetNotFound, recordType, name)
+			}
+
+			if setIdentifier == nil && recordSet.SetIdentifier == nil {
+				return recordSet, nil
+			} else if setIdentifier != nil && recordSet.SetIdentifier != nil && *setIdentifier == *recordSet.SetIdentifier {
+				return recordSet, nil
+			}
+		}
+
+		if !aws.BoolValue(recordOutput.IsTruncated) {
+			break
 		}
+
+		input.StartRecordName = recordOutput.NextRecordName
+		input.StartRecordType = recordOutput.NextRecordType
+		input.StartRecordIdentifier = recordOutput.NextRecordIdentifier
+	}
+
+	return nil, fmt.Errorf("%w: %s %s", errRecordSetNotFound, recordType, name)
+}
+
+// normalizeRecordName converts a record name into the form returned by Route 53 for comparison
+func normalizeRecordName(name string) string {
+	name = strings.ToLower(strings.ReplaceAll(name, "\\052", "*"))
+	if !strings.HasSuffix(name, ".") {
+		name += "."
+	}
+
+	return name
+}
+
+// getDNSName returns the EC2 DNS name of the instance, the public one for public zones
+func getDNSName(config *Route53ZoneConfig, ec2Instance *ec2.Instance) ([]*string, error) {
+	dnsName, kind := ec2Instance.PrivateDnsName, "private"
+	if config.IsPublic {
+		dnsName, kind = ec2Instance.PublicDnsName, "public"
+	}
+
+	if aws.StringValue(dnsName) == "" {
+		return nil, fmt.Errorf("instance %s has no %s DNS name", aws.StringValue(ec2Instance.InstanceId), kind)
+	}
+
+	return []*string{dnsName}, nil
+}
+
+// getOwners returns instance IDs listed in a TXT ownership record
+func getOwners(recordSet *route53.ResourceRecordSet) []string {
+	var owners []string
+	for _, resourceRecord := range recordSet.ResourceRecords {
+		owners = append(owners, strings.Trim(aws.StringValue(resourceRecord.Value), "\""))
 	}
 
-	return nil, fmt.Errorf("Could not find A record or SetIdentifier did not match: %s", name)
+	return owners
 }
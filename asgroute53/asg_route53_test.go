@@ -2,6 +2,7 @@ package asgroute53
 
 import (
 	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -10,49 +11,63 @@ import (
 )
 
 func TestASGRoute53_DeleteRecordSets(t *testing.T) {
+	ownedRecordSets := []*route53.ResourceRecordSet{
+		{
+			Name: aws.String("foo.example.com."),
+			Type: aws.String("A"),
+			ResourceRecords: []*route53.ResourceRecord{
+				{
+					Value: aws.String("10.0.0.1"),
+				},
+			},
+			SetIdentifier: aws.String("identifier"),
+		},
+		{
+			Name: aws.String("foo.example.com."),
+			Type: aws.String("TXT"),
+			ResourceRecords: []*route53.ResourceRecord{
+				{
+					Value: aws.String("\"i-123456789abcdef\""),
+				},
+			},
+			SetIdentifier: aws.String("identifier"),
+		},
+	}
 	type args struct {
 		config      *Route53ZoneConfig
 		ec2Instance *ec2.Instance
 	}
 	tests := []struct {
-		name    string
-		r       *ASGRoute53
-		args    args
-		wantErr bool
+		name        string
+		m           *mockedRoute53
+		args        args
+		wantChanges int
+		wantErr     bool
 	}{
 		{
 			name: "not-found",
-			r: New(&mockedRoute53{
-				listResourceRecordSetsOutput: &route53.ListResourceRecordSetsOutput{
-					ResourceRecordSets: []*route53.ResourceRecordSet{},
-				},
-			}),
+			m: &mockedRoute53{
+				resourceRecordSets: []*route53.ResourceRecordSet{},
+			},
 			args: args{
 				config: &Route53ZoneConfig{
 					HostedZoneID:  "ID",
 					DNSRecords:    []string{"foo.example.com"},
 					SetIdentifier: aws.String("identifier"),
 				},
+				ec2Instance: &ec2.Instance{
+					InstanceId: aws.String("i-123456789abcdef"),
+				},
 			},
-			wantErr: true,
+			wantChanges: 0,
+			wantErr:     false,
 		},
 		{
 			name: "found",
-			r: New(&mockedRoute53{
-				listResourceRecordSetsOutput: &route53.ListResourceRecordSetsOutput{
-					ResourceRecordSets: []*route53.ResourceRecordSet{
-						{
-							ResourceRecords: []*route53.ResourceRecord{
-								{
-									Value: aws.String("foo.example.com"),
-								},
-							},
-							SetIdentifier: aws.String("identifier"),
-						},
-					},
-				},
+			m: &mockedRoute53{
+				resourceRecordSets:             ownedRecordSets,
 				changeResourceRecordSetsOutput: &route53.ChangeResourceRecordSetsOutput{},
-			}),
+			},
 			args: args{
 				config: &Route53ZoneConfig{
 					HostedZoneID:  "ID",
@@ -63,24 +78,34 @@ func TestASGRoute53_DeleteRecordSets(t *testing.T) {
 					InstanceId: aws.String("i-123456789abcdef"),
 				},
 			},
-			wantErr: false,
+			wantChanges: 2,
+			wantErr:     false,
 		},
 		{
 			name: "found-no-set-identifier",
-			r: New(&mockedRoute53{
-				listResourceRecordSetsOutput: &route53.ListResourceRecordSetsOutput{
-					ResourceRecordSets: []*route53.ResourceRecordSet{
-						{
-							ResourceRecords: []*route53.ResourceRecord{
-								{
-									Value: aws.String("foo.example.com"),
-								},
+			m: &mockedRoute53{
+				resourceRecordSets: []*route53.ResourceRecordSet{
+					{
+						Name: aws.String("foo.example.com."),
+						Type: aws.String("A"),
+						ResourceRecords: []*route53.ResourceRecord{
+							{
+								Value: aws.String("10.0.0.1"),
+							},
+						},
+					},
+					{
+						Name: aws.String("foo.example.com."),
+						Type: aws.String("TXT"),
+						ResourceRecords: []*route53.ResourceRecord{
+							{
+								Value: aws.String("\"i-123456789abcdef\""),
 							},
 						},
 					},
 				},
 				changeResourceRecordSetsOutput: &route53.ChangeResourceRecordSetsOutput{},
-			}),
+			},
 			args: args{
 				config: &Route53ZoneConfig{
 					HostedZoneID: "ID",
@@ -90,13 +115,52 @@ func TestASGRoute53_DeleteRecordSets(t *testing.T) {
 					InstanceId: aws.String("i-123456789abcdef"),
 				},
 			},
-			wantErr: false,
+			wantChanges: 2,
+			wantErr:     false,
+		},
+		{
+			name: "owned-by-other-instance",
+			m: &mockedRoute53{
+				resourceRecordSets:             ownedRecordSets,
+				changeResourceRecordSetsOutput: &route53.ChangeResourceRecordSetsOutput{},
+			},
+			args: args{
+				config: &Route53ZoneConfig{
+					HostedZoneID:  "ID",
+					DNSRecords:    []string{"foo.example.com"},
+					SetIdentifier: aws.String("identifier"),
+				},
+				ec2Instance: &ec2.Instance{
+					InstanceId: aws.String("i-00000000000000000"),
+				},
+			},
+			wantChanges: 0,
+			wantErr:     false,
+		},
+		{
+			name: "a-record-missing",
+			m: &mockedRoute53{
+				resourceRecordSets:             ownedRecordSets[1:],
+				changeResourceRecordSetsOutput: &route53.ChangeResourceRecordSetsOutput{},
+			},
+			args: args{
+				config: &Route53ZoneConfig{
+					HostedZoneID:  "ID",
+					DNSRecords:    []string{"foo.example.com"},
+					SetIdentifier: aws.String("identifier"),
+				},
+				ec2Instance: &ec2.Instance{
+					InstanceId: aws.String("i-123456789abcdef"),
+				},
+			},
+			wantChanges: 0,
+			wantErr:     true,
 		},
 		{
 			name: "list-error",
-			r: New(&mockedRoute53{
+			m: &mockedRoute53{
 				listResourceRecordSetsError: errors.New("listError"),
-			}),
+			},
 			args: args{
 				config: &Route53ZoneConfig{
 					HostedZoneID:  "ID",
@@ -107,25 +171,15 @@ func TestASGRoute53_DeleteRecordSets(t *testing.T) {
 					InstanceId: aws.String("i-123456789abcdef"),
 				},
 			},
-			wantErr: true,
+			wantChanges: 0,
+			wantErr:     true,
 		},
 		{
 			name: "change-error",
-			r: New(&mockedRoute53{
-				listResourceRecordSetsOutput: &route53.ListResourceRecordSetsOutput{
-					ResourceRecordSets: []*route53.ResourceRecordSet{
-						{
-							ResourceRecords: []*route53.ResourceRecord{
-								{
-									Value: aws.String("foo.example.com"),
-								},
-							},
-							SetIdentifier: aws.String("identifier"),
-						},
-					},
-				},
+			m: &mockedRoute53{
+				resourceRecordSets:           ownedRecordSets,
 				changeResourceRecordSetError: errors.New("changeError"),
-			}),
+			},
 			args: args{
 				config: &Route53ZoneConfig{
 					HostedZoneID:  "ID",
@@ -136,18 +190,110 @@ func TestASGRoute53_DeleteRecordSets(t *testing.T) {
 					InstanceId: aws.String("i-123456789abcdef"),
 				},
 			},
-			wantErr: true,
+			wantChanges: 2,
+			wantErr:     true,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if err := tt.r.DeleteRecordSets(tt.args.config, tt.args.ec2Instance); (err != nil) != tt.wantErr {
+			if _, err := New(tt.m).DeleteRecordSets(tt.args.config, tt.args.ec2Instance); (err != nil) != tt.wantErr {
 				t.Errorf("ASGRoute53.DeleteRecordSets() error = %v, wantErr %v", err, tt.wantErr)
 			}
+			gotChanges := 0
+			for _, input := range tt.m.changeResourceRecordSetsInputs {
+				gotChanges += len(input.ChangeBatch.Changes)
+			}
+			if gotChanges != tt.wantChanges {
+				t.Errorf("ASGRoute53.DeleteRecordSets() changes = %v, want %v", gotChanges, tt.wantChanges)
+			}
 		})
 	}
 }
 
+func TestASGRoute53_DeleteRecordSets_storedTTL(t *testing.T) {
+	m := &mockedRoute53{
+		resourceRecordSets: []*route53.ResourceRecordSet{
+			{
+				Name:            aws.String("foo.example.com."),
+				Type:            aws.String("A"),
+				ResourceRecords: toResourceRecords([]string{"10.0.0.1"}),
+				TTL:             aws.Int64(300),
+			},
+			{
+				Name:            aws.String("foo.example.com."),
+				Type:            aws.String("TXT"),
+				ResourceRecords: toResourceRecords([]string{"\"i-123456789abcdef\""}),
+				TTL:             aws.Int64(60),
+			},
+		},
+		changeResourceRecordSetsOutput: &route53.ChangeResourceRecordSetsOutput{},
+	}
+	_, err := New(m).DeleteRecordSets(&Route53ZoneConfig{
+		HostedZoneID: "ID",
+		DNSRecords:   []string{"foo.example.com"},
+	}, &ec2.Instance{
+		InstanceId: aws.String("i-123456789abcdef"),
+	})
+	if err != nil {
+		t.Fatalf("ASGRoute53.DeleteRecordSets() error = %v", err)
+	}
+
+	want := map[string]int64{"TXT": 60, "A": 300}
+	for _, change := range m.changeResourceRecordSetsInputs[0].ChangeBatch.Changes {
+		if got := aws.Int64Value(change.ResourceRecordSet.TTL); got != want[*change.ResourceRecordSet.Type] {
+			t.Errorf("ASGRoute53.DeleteRecordSets() %s TTL = %v, want %v", *change.ResourceRecordSet.Type, got, want[*change.ResourceRecordSet.Type])
+		}
+	}
+}
+
+func TestASGRoute53_UpsertRecordSets_cname(t *testing.T) {
+	m := &mockedRoute53{
+		changeResourceRecordSetsOutput: &route53.ChangeResourceRecordSetsOutput{},
+	}
+	config := &Route53ZoneConfig{
+		HostedZoneID:  "ID",
+		DNSRecords:    []string{"foo.example.com"},
+		RecordType:    RecordTypeCNAME,
+		RoutingPolicy: RoutingPolicySimple,
+	}
+	_, err := New(m).UpsertRecordSets(config, &ec2.Instance{
+		InstanceId:     aws.String("i-123456789abcdef"),
+		PrivateDnsName: aws.String("ip-10-0-0-1.ec2.internal"),
+	})
+	if err != nil {
+		t.Fatalf("ASGRoute53.UpsertRecordSets() error = %v", err)
+	}
+
+	want := map[string]string{
+		"TXT":   cnameOwnerPrefix + "foo.example.com",
+		"CNAME": "foo.example.com",
+	}
+	changes := m.changeResourceRecordSetsInputs[0].ChangeBatch.Changes
+	if len(changes) != len(want) {
+		t.Fatalf("ASGRoute53.UpsertRecordSets() changes = %v, want %v", len(changes), len(want))
+	}
+	for _, change := range changes {
+		recordSet := change.ResourceRecordSet
+		if got := aws.StringValue(recordSet.Name); got != want[*recordSet.Type] {
+			t.Errorf("ASGRoute53.UpsertRecordSets() %s name = %v, want %v", *recordSet.Type, got, want[*recordSet.Type])
+		}
+	}
+	if got := aws.StringValue(changes[1].ResourceRecordSet.ResourceRecords[0].Value); got != "ip-10-0-0-1.ec2.internal" {
+		t.Errorf("ASGRoute53.UpsertRecordSets() CNAME value = %v, want ip-10-0-0-1.ec2.internal", got)
+	}
+
+	if _, err := New(m).UpsertRecordSets(&Route53ZoneConfig{
+		HostedZoneID: "ID",
+		DNSRecords:   []string{"foo.example.com"},
+		RecordType:   RecordTypeCNAME,
+		IsPublic:     true,
+	}, &ec2.Instance{
+		InstanceId: aws.String("i-123456789abcdef"),
+	}); err == nil {
+		t.Errorf("ASGRoute53.UpsertRecordSets() error = nil, want missing public DNS name error")
+	}
+}
+
 func TestASGRoute53_UpsertRecordSets(t *testing.T) {
 	type args struct {
 		config      *Route53ZoneConfig
@@ -197,12 +343,204 @@ func TestASGRoute53_UpsertRecordSets(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "public-ip-missing",
+			r: New(&mockedRoute53{
+				changeResourceRecordSetsOutput: &route53.ChangeResourceRecordSetsOutput{},
+			}),
+			args: args{
+				config: &Route53ZoneConfig{
+					HostedZoneID:  "ID",
+					DNSRecords:    []string{"foo.example.com"},
+					SetIdentifier: aws.String("identifier"),
+					IsPublic:      true,
+				},
+				ec2Instance: &ec2.Instance{
+					InstanceId:       aws.String("i-123456789abcdef"),
+					PrivateIpAddress: aws.String("0.0.0.0"),
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "dual-stack",
+			r: New(&mockedRoute53{
+				changeResourceRecordSetsOutput: &route53.ChangeResourceRecordSetsOutput{},
+			}),
+			args: args{
+				config: &Route53ZoneConfig{
+					HostedZoneID:  "ID",
+					DNSRecords:    []string{"foo.example.com"},
+					SetIdentifier: aws.String("identifier"),
+					AddressFamily: AddressFamilyDual,
+				},
+				ec2Instance: &ec2.Instance{
+					InstanceId:       aws.String("i-123456789abcdef"),
+					PrivateIpAddress: aws.String("0.0.0.0"),
+					NetworkInterfaces: []*ec2.InstanceNetworkInterface{
+						{
+							Attachment: &ec2.InstanceNetworkInterfaceAttachment{
+								DeviceIndex: aws.Int64(0),
+							},
+							Ipv6Addresses: []*ec2.InstanceIpv6Address{
+								{
+									Ipv6Address: aws.String("2001:db8::1"),
+								},
+							},
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "ipv6-missing",
+			r: New(&mockedRoute53{
+				changeResourceRecordSetsOutput: &route53.ChangeResourceRecordSetsOutput{},
+			}),
+			args: args{
+				config: &Route53ZoneConfig{
+					HostedZoneID:  "ID",
+					DNSRecords:    []string{"foo.example.com"},
+					SetIdentifier: aws.String("identifier"),
+					AddressFamily: AddressFamilyIPv6,
+				},
+				ec2Instance: &ec2.Instance{
+					InstanceId:       aws.String("i-123456789abcdef"),
+					PrivateIpAddress: aws.String("0.0.0.0"),
+				},
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if err := tt.r.UpsertRecordSets(tt.args.config, tt.args.ec2Instance); (err != nil) != tt.wantErr {
+			if _, err := tt.r.UpsertRecordSets(tt.args.config, tt.args.ec2Instance); (err != nil) != tt.wantErr {
 				t.Errorf("ASGRoute53.UpsertRecordSets() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
 	}
 }
+
+func TestASGRoute53_getRecordSet(t *testing.T) {
+	resourceRecordSets := []*route53.ResourceRecordSet{
+		{
+			Name: aws.String("\\052.example.com."),
+			Type: aws.String("A"),
+		},
+		{
+			Name: aws.String("a.example.com."),
+			Type: aws.String("A"),
+		},
+	}
+	for i := 0; i < 250; i++ {
+		resourceRecordSets = append(resourceRecordSets, &route53.ResourceRecordSet{
+			Name:          aws.String("pool.example.com."),
+			Type:          aws.String("A"),
+			SetIdentifier: aws.String(fmt.Sprintf("id-%03d", i)),
+		})
+	}
+	resourceRecordSets = append(resourceRecordSets,
+		&route53.ResourceRecordSet{
+			Name:          aws.String("pool.example.com."),
+			Type:          aws.String("AAAA"),
+			SetIdentifier: aws.String("id-999"),
+		},
+		&route53.ResourceRecordSet{
+			Name: aws.String("sub.pool.example.com."),
+			Type: aws.String("A"),
+		},
+	)
+
+	type args struct {
+		name          string
+		recordType    string
+		setIdentifier *string
+	}
+	tests := []struct {
+		name      string
+		args      args
+		want      *string
+		wantCalls int
+		wantErr   bool
+	}{
+		{
+			name: "last-page",
+			args: args{
+				name:          "pool.example.com",
+				recordType:    "A",
+				setIdentifier: aws.String("id-249"),
+			},
+			want:      aws.String("id-249"),
+			wantCalls: 3,
+			wantErr:   false,
+		},
+		{
+			name: "case-and-trailing-dot",
+			args: args{
+				name:          "POOL.Example.com.",
+				recordType:    "A",
+				setIdentifier: aws.String("id-005"),
+			},
+			want:      aws.String("id-005"),
+			wantCalls: 1,
+			wantErr:   false,
+		},
+		{
+			name: "wildcard",
+			args: args{
+				name:       "*.example.com",
+				recordType: "A",
+			},
+			want:      nil,
+			wantCalls: 1,
+			wantErr:   false,
+		},
+		{
+			name: "identifier-in-other-type",
+			args: args{
+				name:          "pool.example.com",
+				recordType:    "A",
+				setIdentifier: aws.String("id-999"),
+			},
+			wantCalls: 3,
+			wantErr:   true,
+		},
+		{
+			name: "neighbouring-name",
+			args: args{
+				name:       "b.example.com",
+				recordType: "A",
+			},
+			wantCalls: 1,
+			wantErr:   true,
+		},
+		{
+			name: "no-set-identifier-in-pool",
+			args: args{
+				name:       "pool.example.com",
+				recordType: "A",
+			},
+			wantCalls: 3,
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &mockedRoute53{
+				resourceRecordSets: resourceRecordSets,
+			}
+			got, err := New(m).getRecordSet("ID", tt.args.name, tt.args.recordType, tt.args.setIdentifier)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ASGRoute53.getRecordSet() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if m.listResourceRecordSetsCalls != tt.wantCalls {
+				t.Errorf("ASGRoute53.getRecordSet() calls = %v, want %v", m.listResourceRecordSetsCalls, tt.wantCalls)
+			}
+			if err == nil && aws.StringValue(got.SetIdentifier) != aws.StringValue(tt.want) {
+				t.Errorf("ASGRoute53.getRecordSet() = %v, want %v", aws.StringValue(got.SetIdentifier), aws.StringValue(tt.want))
+			}
+		})
+	}
+}
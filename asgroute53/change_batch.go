@@ -0,0 +1,360 @@
+package asgroute53
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/route53"
+)
+
+// maxChangesPerBatch keeps change batches well below the Route 53 limit of 1000 record set changes
+const maxChangesPerBatch = 500
+
+type (
+	// ChangeBatch collects record set changes of several instances by key and submits them per hosted zone
+	ChangeBatch struct {
+		r         *ASGRoute53
+		groups    []*changeGroup
+		changeIDs map[string][]string
+		maxTTLs   map[string]int64
+		// srvConfigs holds configurations whose SRV entries are added once their records exist
+		srvConfigs []*srvConfig
+	}
+	// srvConfig is an expanded zone configuration with SRV records added under a key
+	srvConfig struct {
+		key    string
+		config *Route53ZoneConfig
+	}
+	// changeGroup holds changes that must be applied together for one zone configuration
+	changeGroup struct {
+		key          string
+		hostedZoneID string
+		changes      []*route53.Change
+		// ownedRecords lists the records deleted by the changes because the instance owns them
+		ownedRecords           []string
+		createdHealthCheckIDs  []string
+		obsoleteHealthCheckIDs []string
+	}
+)
+
+// NewChangeBatch creates an empty change batch
+func (r *ASGRoute53) NewChangeBatch() *ChangeBatch {
+	return &ChangeBatch{
+		r:         r,
+		changeIDs: map[string][]string{},
+		maxTTLs:   map[string]int64{},
+	}
+}
+
+// UpsertRecordSets adds changes creating DNS records for an EC2 instance, applying shared records immediately
+func (b *ChangeBatch) UpsertRecordSets(key string, config *Route53ZoneConfig, ec2Instance *ec2.Instance) error {
+	config, err := config.forInstance(ec2Instance)
+	if err != nil {
+		return err
+	}
+
+	if config.ReverseHostedZoneID != nil {
+		reverseGroup, err := b.r.getReverseUpsertChangeGroup(config, ec2Instance)
+		if err != nil {
+			return err
+		}
+		b.addReverse(key, reverseGroup)
+	}
+
+	if config.SharedRecords {
+		changeID, err := b.r.updateSharedRecordSets(config, ec2Instance, true)
+		b.addChangeID(key, changeID)
+		b.addTTL(key, config.maxTTL())
+		return err
+	}
+
+	group, err := b.r.getUpsertChangeGroup(config, ec2Instance)
+	if err != nil {
+		return err
+	}
+
+	b.add(key, group)
+	if config.SRV != nil {
+		b.srvConfigs = append(b.srvConfigs, &srvConfig{key: key, config: config})
+	}
+	return nil
+}
+
+// DeleteRecordSets adds changes deleting DNS records owned by an EC2 instance, applying shared
+// records and SRV entries immediately
+func (b *ChangeBatch) DeleteRecordSets(key string, config *Route53ZoneConfig, ec2Instance *ec2.Instance) error {
+	config, err := config.forInstance(ec2Instance)
+	if err != nil {
+		return err
+	}
+
+	if config.ReverseHostedZoneID != nil {
+		reverseGroup, err := b.r.getReverseDeleteChangeGroup(config, ec2Instance)
+		if err != nil {
+			return err
+		}
+		b.addReverse(key, reverseGroup)
+	}
+
+	if config.SharedRecords {
+		changeID, err := b.r.updateSharedRecordSets(config, ec2Instance, false)
+		b.addChangeID(key, changeID)
+		b.addTTL(key, config.maxTTL())
+		return err
+	}
+
+	group, err := b.r.getDeleteChangeGroup(config, ec2Instance)
+	if err != nil {
+		return err
+	}
+
+	if config.SRV != nil && len(group.ownedRecords) > 0 {
+		srvConfig := *config
+		srvConfig.DNSRecords = group.ownedRecords
+		changeID, err := b.r.updateSRVRecordSet(&srvConfig, false)
+		b.addChangeID(key, changeID)
+		b.addTTL(key, config.TTLFor(config.SRV.Name))
+		if err != nil {
+			return err
+		}
+	}
+
+	b.add(key, group)
+	return nil
+}
+
+func (b *ChangeBatch) add(key string, group *changeGroup) {
+	if len(group.changes) == 0 {
+		return
+	}
+
+	group.key = key
+	b.groups = append(b.groups, group)
+	for _, change := range group.changes {
+		b.addTTL(key, aws.Int64Value(change.ResourceRecordSet.TTL))
+	}
+}
+
+// addReverse adds PTR changes not yet added under the key, since Route 53 rejects duplicate changes
+func (b *ChangeBatch) addReverse(key string, group *changeGroup) {
+	changed := map[string]bool{}
+	for _, added := range b.groups {
+		if added.key != key || added.hostedZoneID != group.hostedZoneID {
+			continue
+		}
+		for _, change := range added.changes {
+			changed[recordSetKey(change.ResourceRecordSet)] = true
+		}
+	}
+
+	var changes []*route53.Change
+	for _, change := range group.changes {
+		if !changed[recordSetKey(change.ResourceRecordSet)] {
+			changes = append(changes, change)
+		}
+	}
+	group.changes = changes
+
+	b.add(key, group)
+}
+
+func (b *ChangeBatch) addTTL(key string, ttl int64) {
+	if ttl > b.maxTTLs[key] {
+		b.maxTTLs[key] = ttl
+	}
+}
+
+// MaxTTL returns the largest TTL of the record sets changed under the key
+func (b *ChangeBatch) MaxTTL(key string) time.Duration {
+	return time.Duration(b.maxTTLs[key]) * time.Second
+}
+
+func (b *ChangeBatch) addChangeID(key string, changeID *string) {
+	if changeID != nil && !containsValue(b.changeIDs[key], *changeID) {
+		b.changeIDs[key] = append(b.changeIDs[key], *changeID)
+	}
+}
+
+// Discard removes changes added under the key, deleting health checks created for them
+func (b *ChangeBatch) Discard(key string) {
+	var groups []*changeGroup
+	for _, group := range b.groups {
+		if group.key != key {
+			groups = append(groups, group)
+			continue
+		}
+
+		if err := b.r.deleteHealthChecks(group.createdHealthCheckIDs); err != nil {
+			fmt.Println("Failed cleaning up health check: ", err)
+		}
+	}
+
+	b.groups = groups
+
+	var srvConfigs []*srvConfig
+	for _, srv := range b.srvConfigs {
+		if srv.key != key {
+			srvConfigs = append(srvConfigs, srv)
+		}
+	}
+	b.srvConfigs = srvConfigs
+}
+
+// Submit sends the collected changes and returns the IDs of the Route 53 changes and errors by key
+func (b *ChangeBatch) Submit() (map[string][]string, map[string]error) {
+	errs := map[string]error{}
+
+	var zoneIDs []string
+	groupsByZone := map[string][]*changeGroup{}
+	for _, group := range b.groups {
+		if _, ok := groupsByZone[group.hostedZoneID]; !ok {
+			zoneIDs = append(zoneIDs, group.hostedZoneID)
+		}
+		groupsByZone[group.hostedZoneID] = append(groupsByZone[group.hostedZoneID], group)
+	}
+
+	for _, zoneID := range zoneIDs {
+		for _, groups := range packChangeGroups(groupsByZone[zoneID]) {
+			b.submitGroups(zoneID, groups, errs)
+		}
+	}
+
+	for _, srv := range b.srvConfigs {
+		if errs[srv.key] != nil {
+			continue
+		}
+
+		changeID, err := b.r.updateSRVRecordSet(srv.config, true)
+		if err != nil {
+			setError(errs, srv.key, err)
+			continue
+		}
+		b.addChangeID(srv.key, changeID)
+		b.addTTL(srv.key, srv.config.TTLFor(srv.config.SRV.Name))
+	}
+
+	return b.changeIDs, errs
+}
+
+// submitGroups applies the change groups in one change batch, resubmitting a failed batch per key
+// since Route 53 rejects the whole batch if any change is invalid
+func (b *ChangeBatch) submitGroups(zoneID string, groups []*changeGroup, errs map[string]error) {
+	var changes []*route53.Change
+	for _, group := range groups {
+		changes = append(changes, group.changes...)
+	}
+
+	fmt.Printf("Submitting %d changes for %d change sets to %s\n", len(changes), len(groups), zoneID)
+	output, err := b.r.route53Client.ChangeResourceRecordSets(&route53.ChangeResourceRecordSetsInput{
+		ChangeBatch: &route53.ChangeBatch{
+			Changes: changes,
+		},
+		HostedZoneId: aws.String(zoneID),
+	})
+
+	if byKey := groupByKey(groups); err != nil && len(byKey) > 1 {
+		fmt.Println("Coalesced change batch failed, submitting change sets of each key separately: ", err)
+		for _, keyGroups := range byKey {
+			b.submitGroups(zoneID, keyGroups, errs)
+		}
+		return
+	}
+
+	for _, group := range groups {
+		if err != nil {
+			if deleteErr := b.r.deleteHealthChecks(group.createdHealthCheckIDs); deleteErr != nil {
+				fmt.Println("Failed cleaning up health check: ", deleteErr)
+			}
+			setError(errs, group.key, err)
+			continue
+		}
+
+		b.addChangeID(group.key, getChangeID(output))
+
+		if deleteErr := b.r.deleteHealthChecks(group.obsoleteHealthCheckIDs); deleteErr != nil {
+			setError(errs, group.key, deleteErr)
+		}
+	}
+}
+
+// groupByKey splits change groups by their key
+func groupByKey(groups []*changeGroup) [][]*changeGroup {
+	var byKey [][]*changeGroup
+	index := map[string]int{}
+	for _, group := range groups {
+		i, ok := index[group.key]
+		if !ok {
+			i = len(byKey)
+			index[group.key] = i
+			byKey = append(byKey, nil)
+		}
+		byKey[i] = append(byKey[i], group)
+	}
+
+	return byKey
+}
+
+func setError(errs map[string]error, key string, err error) {
+	if errs[key] == nil {
+		errs[key] = err
+	}
+}
+
+// packChangeGroups splits change groups into batches, since Route 53 rejects changing a record set twice
+func packChangeGroups(groups []*changeGroup) [][]*changeGroup {
+	var batches [][]*changeGroup
+	var batchKeys []map[string]bool
+	var batchSizes []int
+
+	for _, group := range groups {
+		keys := map[string]bool{}
+		for _, change := range group.changes {
+			keys[recordSetKey(change.ResourceRecordSet)] = true
+		}
+
+		index := -1
+		for i := range batches {
+			if batchSizes[i]+len(group.changes) > maxChangesPerBatch && batchSizes[i] > 0 {
+				continue
+			}
+			if !hasCommonKey(batchKeys[i], keys) {
+				index = i
+				break
+			}
+		}
+
+		if index < 0 {
+			batches = append(batches, nil)
+			batchKeys = append(batchKeys, map[string]bool{})
+			batchSizes = append(batchSizes, 0)
+			index = len(batches) - 1
+		}
+
+		batches[index] = append(batches[index], group)
+		batchSizes[index] += len(group.changes)
+		for key := range keys {
+			batchKeys[index][key] = true
+		}
+	}
+
+	return batches
+}
+
+func recordSetKey(recordSet *route53.ResourceRecordSet) string {
+	return fmt.Sprintf("%s|%s|%s",
+		normalizeRecordName(aws.StringValue(recordSet.Name)),
+		aws.StringValue(recordSet.Type),
+		aws.StringValue(recordSet.SetIdentifier))
+}
+
+func hasCommonKey(a map[string]bool, b map[string]bool) bool {
+	for key := range b {
+		if a[key] {
+			return true
+		}
+	}
+
+	return false
+}
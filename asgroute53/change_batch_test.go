@@ -0,0 +1,190 @@
+package asgroute53
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/route53"
+)
+
+func TestChangeBatch_Submit(t *testing.T) {
+	newConfig := func(zoneID string, setIdentifier string) *Route53ZoneConfig {
+		return &Route53ZoneConfig{
+			HostedZoneID:  zoneID,
+			DNSRecords:    []string{"pool.example.com"},
+			SetIdentifier: aws.String(setIdentifier),
+			RoutingPolicy: RoutingPolicyMultivalue,
+		}
+	}
+	newInstance := func(instanceID string) *ec2.Instance {
+		return &ec2.Instance{
+			InstanceId:       aws.String(instanceID),
+			PrivateIpAddress: aws.String("10.0.0.1"),
+		}
+	}
+	type upsert struct {
+		key      string
+		config   *Route53ZoneConfig
+		instance *ec2.Instance
+	}
+	tests := []struct {
+		name        string
+		m           *mockedRoute53
+		upserts     []upsert
+		discard     string
+		wantCalls   int
+		wantErrKeys []string
+	}{
+		{
+			name: "coalesced",
+			m: &mockedRoute53{
+				changeResourceRecordSetsOutput: &route53.ChangeResourceRecordSetsOutput{},
+			},
+			upserts: []upsert{
+				{"m1", newConfig("ZONE-A", "{instance-id}"), newInstance("i-1")},
+				{"m2", newConfig("ZONE-A", "{instance-id}"), newInstance("i-2")},
+				{"m3", newConfig("ZONE-A", "{instance-id}"), newInstance("i-3")},
+			},
+			wantCalls:   1,
+			wantErrKeys: nil,
+		},
+		{
+			name: "per-zone",
+			m: &mockedRoute53{
+				changeResourceRecordSetsOutput: &route53.ChangeResourceRecordSetsOutput{},
+			},
+			upserts: []upsert{
+				{"m1", newConfig("ZONE-A", "{instance-id}"), newInstance("i-1")},
+				{"m2", newConfig("ZONE-B", "{instance-id}"), newInstance("i-2")},
+				{"m3", newConfig("ZONE-A", "{instance-id}"), newInstance("i-3")},
+			},
+			wantCalls:   2,
+			wantErrKeys: nil,
+		},
+		{
+			name: "same-record-set",
+			m: &mockedRoute53{
+				changeResourceRecordSetsOutput: &route53.ChangeResourceRecordSetsOutput{},
+			},
+			upserts: []upsert{
+				{"m1", newConfig("ZONE-A", "static"), newInstance("i-1")},
+				{"m2", newConfig("ZONE-A", "static"), newInstance("i-2")},
+			},
+			wantCalls:   2,
+			wantErrKeys: nil,
+		},
+		{
+			name: "zone-error",
+			m: &mockedRoute53{
+				changeResourceRecordSetsOutput: &route53.ChangeResourceRecordSetsOutput{},
+				changeResourceRecordSetErrors: map[string]error{
+					"ZONE-B": errors.New("changeError"),
+				},
+			},
+			upserts: []upsert{
+				{"m1", newConfig("ZONE-A", "{instance-id}"), newInstance("i-1")},
+				{"m2", newConfig("ZONE-B", "{instance-id}"), newInstance("i-2")},
+				{"m3", newConfig("ZONE-B", "{instance-id}"), newInstance("i-3")},
+			},
+			wantCalls:   4,
+			wantErrKeys: []string{"m2", "m3"},
+		},
+		{
+			name: "coalesced-error",
+			m: &mockedRoute53{
+				changeResourceRecordSetsOutput: &route53.ChangeResourceRecordSetsOutput{},
+				changeResourceRecordSetQueue: []error{
+					awserr.New(route53.ErrCodeInvalidChangeBatch, "stale delete", nil),
+					nil,
+					awserr.New(route53.ErrCodeInvalidChangeBatch, "stale delete", nil),
+					nil,
+				},
+			},
+			upserts: []upsert{
+				{"m1", newConfig("ZONE-A", "{instance-id}"), newInstance("i-1")},
+				{"m2", newConfig("ZONE-A", "{instance-id}"), newInstance("i-2")},
+				{"m3", newConfig("ZONE-A", "{instance-id}"), newInstance("i-3")},
+			},
+			wantCalls:   4,
+			wantErrKeys: []string{"m2"},
+		},
+		{
+			name: "single-key-error",
+			m: &mockedRoute53{
+				changeResourceRecordSetsOutput: &route53.ChangeResourceRecordSetsOutput{},
+				changeResourceRecordSetQueue: []error{
+					awserr.New(route53.ErrCodeInvalidChangeBatch, "stale delete", nil),
+				},
+			},
+			upserts: []upsert{
+				{"m1", newConfig("ZONE-A", "{instance-id}"), newInstance("i-1")},
+				{"m1", newConfig("ZONE-A", "static"), newInstance("i-1")},
+			},
+			wantCalls:   1,
+			wantErrKeys: []string{"m1"},
+		},
+		{
+			name: "discarded",
+			m: &mockedRoute53{
+				changeResourceRecordSetsOutput: &route53.ChangeResourceRecordSetsOutput{},
+			},
+			upserts: []upsert{
+				{"m1", newConfig("ZONE-A", "{instance-id}"), newInstance("i-1")},
+				{"m2", newConfig("ZONE-B", "{instance-id}"), newInstance("i-2")},
+			},
+			discard:     "m2",
+			wantCalls:   1,
+			wantErrKeys: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			batch := New(tt.m).NewChangeBatch()
+			for _, u := range tt.upserts {
+				if err := batch.UpsertRecordSets(u.key, u.config, u.instance); err != nil {
+					t.Fatalf("ChangeBatch.UpsertRecordSets() error = %v", err)
+				}
+			}
+			if tt.discard != "" {
+				batch.Discard(tt.discard)
+			}
+
+			_, errs := batch.Submit()
+			if len(tt.m.changeResourceRecordSetsInputs) != tt.wantCalls {
+				t.Errorf("ChangeBatch.Submit() calls = %v, want %v", len(tt.m.changeResourceRecordSetsInputs), tt.wantCalls)
+			}
+			var gotErrKeys []string
+			for key := range errs {
+				gotErrKeys = append(gotErrKeys, key)
+			}
+			if len(gotErrKeys) != len(tt.wantErrKeys) || !equalValues(gotErrKeys, tt.wantErrKeys) {
+				t.Errorf("ChangeBatch.Submit() errors = %v, want %v", gotErrKeys, tt.wantErrKeys)
+			}
+		})
+	}
+}
+
+func TestChangeBatch_MaxTTL(t *testing.T) {
+	batch := New(&mockedRoute53{}).NewChangeBatch()
+	err := batch.UpsertRecordSets("m1", &Route53ZoneConfig{
+		HostedZoneID: "ID",
+		DNSRecords:   []string{"foo.example.com"},
+	}, &ec2.Instance{
+		InstanceId:       aws.String("i-1"),
+		PrivateIpAddress: aws.String("10.0.0.1"),
+	})
+	if err != nil {
+		t.Fatalf("ChangeBatch.UpsertRecordSets() error = %v", err)
+	}
+
+	if got := batch.MaxTTL("m1"); got != defaultTTL*time.Second {
+		t.Errorf("ChangeBatch.MaxTTL() = %v, want %v", got, defaultTTL*time.Second)
+	}
+	if got := batch.MaxTTL("m2"); got != 0 {
+		t.Errorf("ChangeBatch.MaxTTL() = %v, want 0", got)
+	}
+}
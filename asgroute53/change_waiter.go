@@ -0,0 +1,68 @@
+package asgroute53
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+)
+
+const (
+	// DeadlineMargin is the time left before the invocation deadline for completing lifecycle actions
+	DeadlineMargin = 5 * time.Second
+	// HeartbeatInterval is how often lifecycle action heartbeats are recorded while waiting
+	HeartbeatInterval = 30 * time.Second
+)
+
+func getChangeID(output *route53.ChangeResourceRecordSetsOutput) *string {
+	if output == nil || output.ChangeInfo == nil {
+		return nil
+	}
+
+	return output.ChangeInfo.Id
+}
+
+// WaitForChanges polls GetChange until every change is INSYNC. heartbeat is called periodically
+// while waiting so that lifecycle hooks do not time out. Waiting stops with an error shortly
+// before the deadline of ctx.
+func (r *ASGRoute53) WaitForChanges(ctx context.Context, changeIDs []string, heartbeat func() error) error {
+	if deadline, ok := ctx.Deadline(); ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline.Add(-DeadlineMargin))
+		defer cancel()
+	}
+
+	lastHeartbeat := time.Now()
+	for _, changeID := range changeIDs {
+		for {
+			output, err := r.route53Client.GetChangeWithContext(ctx, &route53.GetChangeInput{
+				Id: aws.String(changeID),
+			})
+			if err != nil {
+				return err
+			}
+
+			if aws.StringValue(output.ChangeInfo.Status) == route53.ChangeStatusInsync {
+				fmt.Println("Change is INSYNC:", changeID)
+				break
+			}
+
+			if heartbeat != nil && time.Since(lastHeartbeat) >= r.heartbeatInterval {
+				if err := heartbeat(); err != nil {
+					return err
+				}
+				lastHeartbeat = time.Now()
+			}
+
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("timed out waiting for change %s to become INSYNC: %w", changeID, ctx.Err())
+			case <-time.After(r.pollInterval):
+			}
+		}
+	}
+
+	return nil
+}
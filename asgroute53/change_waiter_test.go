@@ -0,0 +1,126 @@
+package asgroute53
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/route53"
+)
+
+func TestASGRoute53_WaitForChanges(t *testing.T) {
+	tests := []struct {
+		name           string
+		m              *mockedRoute53
+		changeIDs      []string
+		timeout        time.Duration
+		heartbeatErr   error
+		wantCalls      int
+		wantHeartbeats bool
+		wantErr        bool
+	}{
+		{
+			name:      "insync",
+			m:         &mockedRoute53{},
+			changeIDs: []string{"/change/C1", "/change/C2"},
+			timeout:   time.Minute,
+			wantCalls: 2,
+			wantErr:   false,
+		},
+		{
+			name: "pending",
+			m: &mockedRoute53{
+				getChangeStatuses: []string{route53.ChangeStatusPending, route53.ChangeStatusPending},
+			},
+			changeIDs:      []string{"/change/C1"},
+			timeout:        time.Minute,
+			wantCalls:      3,
+			wantHeartbeats: true,
+			wantErr:        false,
+		},
+		{
+			name: "heartbeat-error",
+			m: &mockedRoute53{
+				getChangeStatuses: []string{route53.ChangeStatusPending, route53.ChangeStatusPending},
+			},
+			changeIDs:      []string{"/change/C1"},
+			timeout:        time.Minute,
+			heartbeatErr:   errors.New("heartbeatError"),
+			wantCalls:      1,
+			wantHeartbeats: true,
+			wantErr:        true,
+		},
+		{
+			name: "deadline",
+			m: &mockedRoute53{
+				getChangeStatuses: []string{route53.ChangeStatusPending, route53.ChangeStatusPending},
+			},
+			changeIDs:      []string{"/change/C1"},
+			timeout:        DeadlineMargin,
+			wantCalls:      1,
+			wantHeartbeats: true,
+			wantErr:        true,
+		},
+		{
+			name: "get-change-error",
+			m: &mockedRoute53{
+				getChangeError: errors.New("getChangeError"),
+			},
+			changeIDs: []string{"/change/C1"},
+			timeout:   time.Minute,
+			wantCalls: 1,
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := New(tt.m)
+			r.pollInterval = time.Millisecond
+			r.heartbeatInterval = 0
+
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+
+			heartbeats := 0
+			err := r.WaitForChanges(ctx, tt.changeIDs, func() error {
+				heartbeats++
+				return tt.heartbeatErr
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ASGRoute53.WaitForChanges() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.m.getChangeCalls != tt.wantCalls {
+				t.Errorf("ASGRoute53.WaitForChanges() calls = %v, want %v", tt.m.getChangeCalls, tt.wantCalls)
+			}
+			if (heartbeats > 0) != tt.wantHeartbeats {
+				t.Errorf("ASGRoute53.WaitForChanges() heartbeats = %v, wantHeartbeats %v", heartbeats, tt.wantHeartbeats)
+			}
+		})
+	}
+}
+
+func TestASGRoute53_UpsertRecordSets_changeIDs(t *testing.T) {
+	m := &mockedRoute53{
+		changeResourceRecordSetsOutput: &route53.ChangeResourceRecordSetsOutput{
+			ChangeInfo: &route53.ChangeInfo{
+				Id: aws.String("/change/C1"),
+			},
+		},
+	}
+	changeIDs, err := New(m).UpsertRecordSets(&Route53ZoneConfig{
+		HostedZoneID: "ID",
+		DNSRecords:   []string{"foo.example.com"},
+	}, &ec2.Instance{
+		InstanceId:       aws.String("i-123456789abcdef"),
+		PrivateIpAddress: aws.String("10.0.0.1"),
+	})
+	if err != nil {
+		t.Fatalf("ASGRoute53.UpsertRecordSets() error = %v", err)
+	}
+	if len(changeIDs) != 1 || changeIDs[0] != "/change/C1" {
+		t.Errorf("ASGRoute53.UpsertRecordSets() changeIDs = %v, want [/change/C1]", changeIDs)
+	}
+}
@@ -0,0 +1,19 @@
+package asgroute53
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// MergeTags returns the tags of the Auto Scaling group with the instance tags added, so that the
+// configuration can be tagged once on the group and overridden per instance
+func MergeTags(groupTags []*ec2.Tag, instanceTags []*ec2.Tag) []*ec2.Tag {
+	var merged []*ec2.Tag
+	for _, tag := range groupTags {
+		if findTagValue(instanceTags, aws.StringValue(tag.Key)) == nil {
+			merged = append(merged, tag)
+		}
+	}
+
+	return append(merged, instanceTags...)
+}
@@ -0,0 +1,47 @@
+package asgroute53
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+func TestMergeTags(t *testing.T) {
+	tag := func(key string, value string) *ec2.Tag {
+		return &ec2.Tag{Key: aws.String(key), Value: aws.String(value)}
+	}
+	tests := []struct {
+		name         string
+		groupTags    []*ec2.Tag
+		instanceTags []*ec2.Tag
+		want         []*ec2.Tag
+	}{
+		{
+			name:         "group-only",
+			groupTags:    []*ec2.Tag{tag(privateHostedZoneIDKey, "ZONE")},
+			instanceTags: nil,
+			want:         []*ec2.Tag{tag(privateHostedZoneIDKey, "ZONE")},
+		},
+		{
+			name:         "instance-only",
+			groupTags:    nil,
+			instanceTags: []*ec2.Tag{tag(privateHostedZoneIDKey, "ZONE")},
+			want:         []*ec2.Tag{tag(privateHostedZoneIDKey, "ZONE")},
+		},
+		{
+			name:         "instance-wins",
+			groupTags:    []*ec2.Tag{tag(privateHostedZoneIDKey, "GROUP"), tag(privateDNSRecordsKey, "foo.example.com")},
+			instanceTags: []*ec2.Tag{tag(privateHostedZoneIDKey, "INSTANCE")},
+			want:         []*ec2.Tag{tag(privateDNSRecordsKey, "foo.example.com"), tag(privateHostedZoneIDKey, "INSTANCE")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MergeTags(tt.groupTags, tt.instanceTags); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MergeTags() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
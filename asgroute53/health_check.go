@@ -0,0 +1,77 @@
+package asgroute53
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+)
+
+const instanceIDTagKey = "asg-route53-lambda:instance-id"
+
+// HealthCheckConfig holds parameters of the health check created for each instance
+type HealthCheckConfig struct {
+	Type         string
+	Port         int64
+	ResourcePath *string
+}
+
+// createHealthCheck creates a health check for the instance address and tags it with the instance ID.
+// Route 53 keeps caller references of deleted health checks, so every attempt uses a new one.
+func (r *ASGRoute53) createHealthCheck(config *Route53ZoneConfig, instanceID string, ipAddress *string) (*string, error) {
+	output, err := r.route53Client.CreateHealthCheck(&route53.CreateHealthCheckInput{
+		CallerReference: aws.String(fmt.Sprintf("%s-%d-%08x", instanceID, time.Now().Unix(), rand.Uint32())),
+		HealthCheckConfig: &route53.HealthCheckConfig{
+			Type:         aws.String(config.HealthCheck.Type),
+			IPAddress:    ipAddress,
+			Port:         aws.Int64(config.HealthCheck.Port),
+			ResourcePath: config.HealthCheck.ResourcePath,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	healthCheckID := output.HealthCheck.Id
+	fmt.Println("Created health check", *healthCheckID)
+
+	_, err = r.route53Client.ChangeTagsForResource(&route53.ChangeTagsForResourceInput{
+		ResourceId:   healthCheckID,
+		ResourceType: aws.String(route53.TagResourceTypeHealthcheck),
+		AddTags: []*route53.Tag{
+			{
+				Key:   aws.String("Name"),
+				Value: aws.String(instanceID),
+			},
+			{
+				Key:   aws.String(instanceIDTagKey),
+				Value: aws.String(instanceID),
+			},
+		},
+	})
+	if err != nil {
+		if deleteErr := r.deleteHealthChecks([]string{*healthCheckID}); deleteErr != nil {
+			fmt.Println("Failed cleaning up health check: ", deleteErr)
+		}
+		return nil, err
+	}
+
+	return healthCheckID, nil
+}
+
+func (r *ASGRoute53) deleteHealthChecks(healthCheckIDs []string) error {
+	for _, healthCheckID := range healthCheckIDs {
+		_, err := r.route53Client.DeleteHealthCheck(&route53.DeleteHealthCheckInput{
+			HealthCheckId: aws.String(healthCheckID),
+		})
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("Deleted health check", healthCheckID)
+	}
+
+	return nil
+}
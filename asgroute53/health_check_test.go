@@ -0,0 +1,302 @@
+package asgroute53
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/route53"
+)
+
+func TestASGRoute53_UpsertRecordSets_healthCheck(t *testing.T) {
+	config := &Route53ZoneConfig{
+		HostedZoneID:  "ID",
+		DNSRecords:    []string{"foo.example.com"},
+		SetIdentifier: aws.String("identifier"),
+		IsPublic:      true,
+		RoutingPolicy: RoutingPolicyMultivalue,
+		HealthCheck: &HealthCheckConfig{
+			Type:         route53.HealthCheckTypeHttp,
+			Port:         80,
+			ResourcePath: aws.String("/"),
+		},
+	}
+	instance := &ec2.Instance{
+		InstanceId:      aws.String("i-123456789abcdef"),
+		PublicIpAddress: aws.String("203.0.113.1"),
+	}
+	tests := []struct {
+		name        string
+		m           *mockedRoute53
+		wantDeleted []string
+		wantErr     bool
+	}{
+		{
+			name: "attached",
+			m: &mockedRoute53{
+				resourceRecordSets: []*route53.ResourceRecordSet{},
+				createHealthCheckOutput: &route53.CreateHealthCheckOutput{
+					HealthCheck: &route53.HealthCheck{
+						Id: aws.String("health-check-id"),
+					},
+				},
+				changeResourceRecordSetsOutput: &route53.ChangeResourceRecordSetsOutput{},
+			},
+			wantDeleted: nil,
+			wantErr:     false,
+		},
+		{
+			name: "replaced",
+			m: &mockedRoute53{
+				resourceRecordSets: []*route53.ResourceRecordSet{
+					{
+						Name:            aws.String("foo.example.com."),
+						Type:            aws.String("A"),
+						ResourceRecords: toResourceRecords([]string{"203.0.113.9"}),
+						SetIdentifier:   aws.String("identifier"),
+						HealthCheckId:   aws.String("previous-health-check-id"),
+					},
+				},
+				createHealthCheckOutput: &route53.CreateHealthCheckOutput{
+					HealthCheck: &route53.HealthCheck{
+						Id: aws.String("health-check-id"),
+					},
+				},
+				changeResourceRecordSetsOutput: &route53.ChangeResourceRecordSetsOutput{},
+			},
+			wantDeleted: []string{"previous-health-check-id"},
+			wantErr:     false,
+		},
+		{
+			name: "create-error",
+			m: &mockedRoute53{
+				resourceRecordSets:     []*route53.ResourceRecordSet{},
+				createHealthCheckError: errors.New("createError"),
+			},
+			wantDeleted: nil,
+			wantErr:     true,
+		},
+		{
+			name: "lookup-error",
+			m: &mockedRoute53{
+				listResourceRecordSetsError: errors.New("listError"),
+			},
+			wantDeleted: nil,
+			wantErr:     true,
+		},
+		{
+			name: "change-error",
+			m: &mockedRoute53{
+				resourceRecordSets: []*route53.ResourceRecordSet{},
+				createHealthCheckOutput: &route53.CreateHealthCheckOutput{
+					HealthCheck: &route53.HealthCheck{
+						Id: aws.String("health-check-id"),
+					},
+				},
+				changeResourceRecordSetError: errors.New("changeError"),
+			},
+			wantDeleted: []string{"health-check-id"},
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.m).UpsertRecordSets(config, instance)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ASGRoute53.UpsertRecordSets() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(tt.m.deletedHealthCheckIDs, tt.wantDeleted) {
+				t.Errorf("ASGRoute53.UpsertRecordSets() deleted = %v, want %v", tt.m.deletedHealthCheckIDs, tt.wantDeleted)
+			}
+			if err != nil {
+				return
+			}
+			for _, change := range tt.m.changeResourceRecordSetsInputs[0].ChangeBatch.Changes {
+				wantHealthCheckID := "health-check-id"
+				if *change.ResourceRecordSet.Type == "TXT" {
+					wantHealthCheckID = ""
+				}
+				if aws.StringValue(change.ResourceRecordSet.HealthCheckId) != wantHealthCheckID {
+					t.Errorf("ASGRoute53.UpsertRecordSets() %s HealthCheckId = %v, want %v",
+						*change.ResourceRecordSet.Type, aws.StringValue(change.ResourceRecordSet.HealthCheckId), wantHealthCheckID)
+				}
+			}
+		})
+	}
+}
+
+func TestASGRoute53_DeleteRecordSets_healthCheck(t *testing.T) {
+	m := &mockedRoute53{
+		resourceRecordSets: []*route53.ResourceRecordSet{
+			{
+				Name:            aws.String("foo.example.com."),
+				Type:            aws.String("A"),
+				ResourceRecords: toResourceRecords([]string{"203.0.113.1"}),
+				SetIdentifier:   aws.String("identifier"),
+				HealthCheckId:   aws.String("health-check-id"),
+			},
+			{
+				Name:            aws.String("foo.example.com."),
+				Type:            aws.String("TXT"),
+				ResourceRecords: toResourceRecords([]string{"\"i-123456789abcdef\""}),
+				SetIdentifier:   aws.String("identifier"),
+			},
+		},
+		changeResourceRecordSetsOutput: &route53.ChangeResourceRecordSetsOutput{},
+	}
+	config := &Route53ZoneConfig{
+		HostedZoneID:  "ID",
+		DNSRecords:    []string{"foo.example.com"},
+		SetIdentifier: aws.String("identifier"),
+		IsPublic:      true,
+		RoutingPolicy: RoutingPolicyMultivalue,
+		HealthCheck: &HealthCheckConfig{
+			Type: route53.HealthCheckTypeTcp,
+			Port: 22,
+		},
+	}
+
+	_, err := New(m).DeleteRecordSets(config, &ec2.Instance{
+		InstanceId: aws.String("i-123456789abcdef"),
+	})
+	if err != nil {
+		t.Fatalf("ASGRoute53.DeleteRecordSets() error = %v", err)
+	}
+
+	aChange := m.changeResourceRecordSetsInputs[0].ChangeBatch.Changes[1]
+	if aws.StringValue(aChange.ResourceRecordSet.HealthCheckId) != "health-check-id" {
+		t.Errorf("ASGRoute53.DeleteRecordSets() HealthCheckId = %v, want health-check-id", aws.StringValue(aChange.ResourceRecordSet.HealthCheckId))
+	}
+	if !reflect.DeepEqual(m.deletedHealthCheckIDs, []string{"health-check-id"}) {
+		t.Errorf("ASGRoute53.DeleteRecordSets() deleted = %v, want [health-check-id]", m.deletedHealthCheckIDs)
+	}
+}
+
+func TestASGRoute53_createHealthCheck_callerReference(t *testing.T) {
+	m := &mockedRoute53{
+		createHealthCheckOutput: &route53.CreateHealthCheckOutput{
+			HealthCheck: &route53.HealthCheck{
+				Id: aws.String("health-check-id"),
+			},
+		},
+	}
+	config := &Route53ZoneConfig{
+		HostedZoneID: "ID",
+		HealthCheck: &HealthCheckConfig{
+			Type: route53.HealthCheckTypeTcp,
+			Port: 22,
+		},
+	}
+
+	// A redelivered launch creates the health check again after the first one was deleted
+	r := New(m)
+	for i := 0; i < 2; i++ {
+		if _, err := r.createHealthCheck(config, "i-123456789abcdef", aws.String("203.0.113.1")); err != nil {
+			t.Fatalf("ASGRoute53.createHealthCheck() error = %v", err)
+		}
+	}
+
+	first := aws.StringValue(m.createHealthCheckInputs[0].CallerReference)
+	second := aws.StringValue(m.createHealthCheckInputs[1].CallerReference)
+	if first == second {
+		t.Errorf("ASGRoute53.createHealthCheck() reused caller reference %v", first)
+	}
+	if len(first) > 64 {
+		t.Errorf("ASGRoute53.createHealthCheck() caller reference %v is longer than 64 characters", first)
+	}
+}
+
+func TestASGRoute53_UpsertRecordSets_healthCheckSeveralAddresses(t *testing.T) {
+	m := &mockedRoute53{
+		createHealthCheckOutput: &route53.CreateHealthCheckOutput{
+			HealthCheck: &route53.HealthCheck{
+				Id: aws.String("health-check-id"),
+			},
+		},
+	}
+	config := &Route53ZoneConfig{
+		HostedZoneID:  "ID",
+		DNSRecords:    []string{"foo.example.com"},
+		SetIdentifier: aws.String("identifier"),
+		IsPublic:      true,
+		RoutingPolicy: RoutingPolicyMultivalue,
+		AddressSource: AddressSourceSecondary,
+		HealthCheck: &HealthCheckConfig{
+			Type: route53.HealthCheckTypeTcp,
+			Port: 22,
+		},
+	}
+	instance := &ec2.Instance{
+		InstanceId: aws.String("i-123456789abcdef"),
+		NetworkInterfaces: []*ec2.InstanceNetworkInterface{
+			{
+				PrivateIpAddresses: []*ec2.InstancePrivateIpAddress{
+					{
+						PrivateIpAddress: aws.String("10.0.0.2"),
+						Association:      &ec2.InstanceNetworkInterfaceAssociation{PublicIp: aws.String("203.0.113.2")},
+					},
+					{
+						PrivateIpAddress: aws.String("10.0.0.3"),
+						Association:      &ec2.InstanceNetworkInterfaceAssociation{PublicIp: aws.String("203.0.113.3")},
+					},
+				},
+			},
+		},
+	}
+
+	if _, err := New(m).UpsertRecordSets(config, instance); err == nil {
+		t.Errorf("ASGRoute53.UpsertRecordSets() error = nil, want error")
+	}
+	if len(m.createHealthCheckInputs) != 0 {
+		t.Errorf("ASGRoute53.UpsertRecordSets() created %d health checks, want 0", len(m.createHealthCheckInputs))
+	}
+}
+
+func TestASGRoute53_UpsertRecordSets_healthCheckRedelivered(t *testing.T) {
+	m := &mockedRoute53{
+		resourceRecordSets:             []*route53.ResourceRecordSet{},
+		changeResourceRecordSetsOutput: &route53.ChangeResourceRecordSetsOutput{},
+		applyChanges:                   true,
+	}
+	config := &Route53ZoneConfig{
+		HostedZoneID:  "ID",
+		DNSRecords:    []string{"foo.example.com"},
+		SetIdentifier: aws.String("identifier"),
+		IsPublic:      true,
+		RoutingPolicy: RoutingPolicyMultivalue,
+		HealthCheck: &HealthCheckConfig{
+			Type: route53.HealthCheckTypeTcp,
+			Port: 22,
+		},
+	}
+	instance := &ec2.Instance{
+		InstanceId:      aws.String("i-123456789abcdef"),
+		PublicIpAddress: aws.String("203.0.113.1"),
+	}
+
+	r := New(m)
+	for i := 0; i < 2; i++ {
+		if _, err := r.UpsertRecordSets(config, instance); err != nil {
+			t.Fatalf("ASGRoute53.UpsertRecordSets() error = %v", err)
+		}
+	}
+
+	var live []string
+	for i := range m.createHealthCheckInputs {
+		healthCheckID := fmt.Sprintf("health-check-%d", i+1)
+		if !containsValue(m.deletedHealthCheckIDs, healthCheckID) {
+			live = append(live, healthCheckID)
+		}
+	}
+
+	recordSet, err := r.getRecordSet("ID", "foo.example.com", "A", config.SetIdentifier)
+	if err != nil {
+		t.Fatalf("ASGRoute53.getRecordSet() error = %v", err)
+	}
+	if !reflect.DeepEqual(live, []string{aws.StringValue(recordSet.HealthCheckId)}) {
+		t.Errorf("ASGRoute53.UpsertRecordSets() live health checks = %v, want [%v]", live, aws.StringValue(recordSet.HealthCheckId))
+	}
+}
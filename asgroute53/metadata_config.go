@@ -0,0 +1,218 @@
+package asgroute53
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// Modes of combining the notification metadata config with the zone tags of the instance
+const (
+	// MetadataModeMerge loads the zone tags in addition to the zones of the metadata
+	MetadataModeMerge = "merge"
+	// MetadataModeReplace ignores the zone tags
+	MetadataModeReplace = "replace"
+)
+
+type (
+	// MetadataConfig holds zone configs parsed from the NotificationMetadata of a lifecycle hook.
+	// The configs are validated but their hosted zones are not resolved yet.
+	MetadataConfig struct {
+		Mode    string
+		configs []*Route53ZoneConfig
+	}
+	// metadataDocument is the JSON document accepted in NotificationMetadata. Other top-level keys
+	// may be meant for other consumers and are ignored.
+	metadataDocument struct {
+		Mode  string          `json:"mode"`
+		Zones json.RawMessage `json:"zones"`
+	}
+	// metadataZoneConfig configures the records of one zone. Fields are named and validated like
+	// the zone tags without their asg-route53-lambda:private- or public- prefix.
+	metadataZoneConfig struct {
+		Public                 bool             `json:"public"`
+		HostedZoneID           *string          `json:"hosted-zone-id"`
+		DNSRecords             []string         `json:"dns-records"`
+		SetIdentifier          *string          `json:"set-identifier"`
+		AddressFamily          *string          `json:"address-family"`
+		SharedRecords          *bool            `json:"shared-records"`
+		RoutingPolicy          *string          `json:"routing-policy"`
+		Weight                 *int64           `json:"weight"`
+		Region                 *string          `json:"region"`
+		GeoLocationContinent   *string          `json:"geolocation-continent"`
+		GeoLocationCountry     *string          `json:"geolocation-country"`
+		GeoLocationSubdivision *string          `json:"geolocation-subdivision"`
+		Failover               *string          `json:"failover"`
+		HealthCheckType        *string          `json:"health-check-type"`
+		HealthCheckPort        *int64           `json:"health-check-port"`
+		HealthCheckPath        *string          `json:"health-check-path"`
+		TTL                    *int64           `json:"ttl"`
+		RecordTTLs             map[string]int64 `json:"record-ttls"`
+		AddressSource          *string          `json:"address-source"`
+		NetworkInterfaceIndex  *int64           `json:"network-interface-index"`
+		NetworkInterfaceTag    *string          `json:"network-interface-tag"`
+		RecordType             *string          `json:"record-type"`
+		SRVService             *string          `json:"srv-service"`
+		SRVProtocol            *string          `json:"srv-protocol"`
+		SRVPort                *int64           `json:"srv-port"`
+		SRVPriority            *int64           `json:"srv-priority"`
+		SRVWeight              *int64           `json:"srv-weight"`
+		ReverseHostedZoneID    *string          `json:"reverse-hosted-zone-id"`
+	}
+)
+
+// metadataKeys names the fields of metadata zones, so that errors of the tag parser refer to them
+var metadataKeys = zoneTagKeys{
+	hostedZoneID:           "hosted-zone-id",
+	dnsRecords:             "dns-records",
+	setIdentifier:          "set-identifier",
+	addressFamily:          "address-family",
+	sharedRecords:          "shared-records",
+	routingPolicy:          "routing-policy",
+	weight:                 "weight",
+	region:                 "region",
+	geoLocationContinent:   "geolocation-continent",
+	geoLocationCountry:     "geolocation-country",
+	geoLocationSubdivision: "geolocation-subdivision",
+	failover:               "failover",
+	healthCheckType:        "health-check-type",
+	healthCheckPort:        "health-check-port",
+	healthCheckPath:        "health-check-path",
+	ttl:                    "ttl",
+	addressSource:          "address-source",
+	networkInterfaceIndex:  "network-interface-index",
+	networkInterfaceTag:    "network-interface-tag",
+	recordType:             "record-type",
+	srvService:             "srv-service",
+	srvProtocol:            "srv-protocol",
+	srvPort:                "srv-port",
+	srvPriority:            "srv-priority",
+	srvWeight:              "srv-weight",
+	reverseHostedZoneID:    "reverse-hosted-zone-id",
+}
+
+// ParseMetadata parses and validates the zone configs of a lifecycle hook's NotificationMetadata
+// without calling Route 53. It returns nil if the metadata is not a JSON object with a zones key,
+// since the metadata of hooks may be meant for other consumers.
+func (l Route53ZoneConfigLoader) ParseMetadata(metadata string) (*MetadataConfig, error) {
+	if !strings.HasPrefix(strings.TrimSpace(metadata), "{") {
+		return nil, nil
+	}
+
+	var document metadataDocument
+	if err := json.Unmarshal([]byte(metadata), &document); err != nil {
+		return nil, fmt.Errorf("invalid notification metadata: %w", err)
+	}
+	if document.Zones == nil {
+		return nil, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(document.Zones))
+	decoder.DisallowUnknownFields()
+
+	var zones []*metadataZoneConfig
+	if err := decoder.Decode(&zones); err != nil {
+		return nil, fmt.Errorf("invalid notification metadata: zones: %w", err)
+	}
+
+	config := &MetadataConfig{Mode: MetadataModeMerge}
+	switch strings.ToLower(document.Mode) {
+	case "", MetadataModeMerge:
+	case MetadataModeReplace:
+		config.Mode = MetadataModeReplace
+	default:
+		return nil, fmt.Errorf("invalid notification metadata: unsupported mode: %s", document.Mode)
+	}
+
+	for i, zone := range zones {
+		zoneConfig, err := l.parseMetadataZone(zone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid notification metadata: zones[%d]: %w", i, err)
+		}
+		config.configs = append(config.configs, zoneConfig)
+	}
+
+	return config, nil
+}
+
+func (l Route53ZoneConfigLoader) parseMetadataZone(zone *metadataZoneConfig) (*Route53ZoneConfig, error) {
+	if zone == nil || len(zone.DNSRecords) == 0 {
+		return nil, fmt.Errorf("%s should not be empty", metadataKeys.dnsRecords)
+	}
+
+	if zone.Public && zone.ReverseHostedZoneID != nil {
+		return nil, fmt.Errorf("%s is only supported for private zones", metadataKeys.reverseHostedZoneID)
+	}
+
+	tags := zone.toTags()
+	return l.parse(&tags, metadataKeys, zone.Public)
+}
+
+// toTags converts the zone to tags named by metadataKeys
+func (z *metadataZoneConfig) toTags() []*ec2.Tag {
+	var tags []*ec2.Tag
+	addString := func(key string, value *string) {
+		if value != nil {
+			tags = append(tags, &ec2.Tag{Key: aws.String(key), Value: value})
+		}
+	}
+	addInt := func(key string, value *int64) {
+		if value != nil {
+			addString(key, aws.String(strconv.FormatInt(*value, 10)))
+		}
+	}
+
+	addString(metadataKeys.hostedZoneID, z.HostedZoneID)
+	addString(metadataKeys.dnsRecords, aws.String(strings.Join(z.DNSRecords, ",")))
+	addString(metadataKeys.setIdentifier, z.SetIdentifier)
+	addString(metadataKeys.addressFamily, z.AddressFamily)
+	if z.SharedRecords != nil {
+		addString(metadataKeys.sharedRecords, aws.String(strconv.FormatBool(*z.SharedRecords)))
+	}
+	addString(metadataKeys.routingPolicy, z.RoutingPolicy)
+	addInt(metadataKeys.weight, z.Weight)
+	addString(metadataKeys.region, z.Region)
+	addString(metadataKeys.geoLocationContinent, z.GeoLocationContinent)
+	addString(metadataKeys.geoLocationCountry, z.GeoLocationCountry)
+	addString(metadataKeys.geoLocationSubdivision, z.GeoLocationSubdivision)
+	addString(metadataKeys.failover, z.Failover)
+	addString(metadataKeys.healthCheckType, z.HealthCheckType)
+	addInt(metadataKeys.healthCheckPort, z.HealthCheckPort)
+	addString(metadataKeys.healthCheckPath, z.HealthCheckPath)
+	addInt(metadataKeys.ttl, z.TTL)
+	for record, ttl := range z.RecordTTLs {
+		addInt(metadataKeys.ttl+":"+record, aws.Int64(ttl))
+	}
+	addString(metadataKeys.addressSource, z.AddressSource)
+	addInt(metadataKeys.networkInterfaceIndex, z.NetworkInterfaceIndex)
+	addString(metadataKeys.networkInterfaceTag, z.NetworkInterfaceTag)
+	addString(metadataKeys.recordType, z.RecordType)
+	addString(metadataKeys.srvService, z.SRVService)
+	addString(metadataKeys.srvProtocol, z.SRVProtocol)
+	addInt(metadataKeys.srvPort, z.SRVPort)
+	addInt(metadataKeys.srvPriority, z.SRVPriority)
+	addInt(metadataKeys.srvWeight, z.SRVWeight)
+	addString(metadataKeys.reverseHostedZoneID, z.ReverseHostedZoneID)
+
+	return tags
+}
+
+// LoadMetadata resolves the hosted zones of the zone configs parsed from notification metadata.
+// Private zones inferred from record names must be associated with vpcID if it is given.
+func (l Route53ZoneConfigLoader) LoadMetadata(metadata *MetadataConfig, vpcID *string) ([]*Route53ZoneConfig, error) {
+	var configs []*Route53ZoneConfig
+	for _, config := range metadata.configs {
+		resolved, err := l.resolve(config, vpcID)
+		if err != nil {
+			return nil, err
+		}
+		configs = append(configs, resolved...)
+	}
+
+	return configs, nil
+}
@@ -0,0 +1,146 @@
+package asgroute53
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+)
+
+func TestRoute53ZoneConfigLoader_ParseMetadata(t *testing.T) {
+	tests := []struct {
+		name        string
+		metadata    string
+		wantNil     bool
+		wantMode    string
+		wantConfigs int
+		wantErr     bool
+	}{
+		{
+			name:     "empty",
+			metadata: "",
+			wantNil:  true,
+		},
+		{
+			name:     "not-json",
+			metadata: "owner=team-a",
+			wantNil:  true,
+		},
+		{
+			name:     "other-consumer",
+			metadata: `{"team": "x", "mode": "blue-green"}`,
+			wantNil:  true,
+		},
+		{
+			name:        "other-keys",
+			metadata:    `{"team": "x", "zones": [{"hosted-zone-id": "ZONE", "dns-records": ["foo.example.com"]}]}`,
+			wantMode:    MetadataModeMerge,
+			wantConfigs: 1,
+		},
+		{
+			name:        "merge-by-default",
+			metadata:    `{"zones": [{"hosted-zone-id": "ZONE", "dns-records": ["foo.example.com"], "ttl": 60}]}`,
+			wantMode:    MetadataModeMerge,
+			wantConfigs: 1,
+		},
+		{
+			name: "replace",
+			metadata: `{"mode": "replace", "zones": [
+				{"hosted-zone-id": "PRIVATE", "dns-records": ["foo.example.com"], "routing-policy": "weighted", "weight": 10, "set-identifier": "{instance-id}"},
+				{"public": true, "dns-records": ["foo.example.com"], "record-ttls": {"foo.example.com": 30}}
+			]}`,
+			wantMode:    MetadataModeReplace,
+			wantConfigs: 2,
+		},
+		{
+			name:     "invalid-json",
+			metadata: `{"zones": [`,
+			wantErr:  true,
+		},
+		{
+			name:     "unknown-field",
+			metadata: `{"zones": [{"hosted-zone-id": "ZONE", "dns-records": ["foo.example.com"], "tll": 60}]}`,
+			wantErr:  true,
+		},
+		{
+			name:     "wrong-type",
+			metadata: `{"zones": [{"hosted-zone-id": "ZONE", "dns-records": ["foo.example.com"], "ttl": "60"}]}`,
+			wantErr:  true,
+		},
+		{
+			name:     "unsupported-mode",
+			metadata: `{"mode": "append", "zones": []}`,
+			wantErr:  true,
+		},
+		{
+			name:     "records-missing",
+			metadata: `{"zones": [{"hosted-zone-id": "ZONE"}]}`,
+			wantErr:  true,
+		},
+		{
+			name:     "invalid-routing-policy",
+			metadata: `{"zones": [{"hosted-zone-id": "ZONE", "dns-records": ["foo.example.com"], "routing-policy": "random"}]}`,
+			wantErr:  true,
+		},
+		{
+			name:     "public-reverse-zone",
+			metadata: `{"zones": [{"public": true, "dns-records": ["foo.example.com"], "reverse-hosted-zone-id": "REVERSE"}]}`,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &mockedRoute53{}
+			got, err := NewZoneConfigLoader(m).ParseMetadata(tt.metadata)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseMetadata() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if m.getHostedZoneCalls != 0 {
+				t.Errorf("ParseMetadata() called Route 53 %d times", m.getHostedZoneCalls)
+			}
+			if err != nil {
+				return
+			}
+			if (got == nil) != tt.wantNil {
+				t.Fatalf("ParseMetadata() = %v, wantNil %v", got, tt.wantNil)
+			}
+			if got == nil {
+				return
+			}
+			if got.Mode != tt.wantMode || len(got.configs) != tt.wantConfigs {
+				t.Errorf("ParseMetadata() mode = %v, configs = %v, want %v, %v", got.Mode, len(got.configs), tt.wantMode, tt.wantConfigs)
+			}
+		})
+	}
+}
+
+func TestRoute53ZoneConfigLoader_LoadMetadata(t *testing.T) {
+	m := &mockedRoute53{
+		hostedZones: []*route53.HostedZone{
+			{Id: aws.String("/hostedzone/PRIVATE"), Name: aws.String("example.com."), Config: &route53.HostedZoneConfig{PrivateZone: aws.Bool(true)}},
+			{Id: aws.String("/hostedzone/PUBLIC"), Name: aws.String("example.com."), Config: &route53.HostedZoneConfig{PrivateZone: aws.Bool(false)}},
+		},
+	}
+	l := NewZoneConfigLoader(m)
+	metadata, err := l.ParseMetadata(`{"zones": [
+		{"hosted-zone-id": "/hostedzone/PRIVATE", "dns-records": ["foo.example.com"], "ttl": 60},
+		{"public": true, "dns-records": ["foo.example.com"], "record-ttls": {"foo.example.com": 30}}
+	]}`)
+	if err != nil {
+		t.Fatalf("ParseMetadata() error = %v", err)
+	}
+
+	configs, err := l.LoadMetadata(metadata, nil)
+	if err != nil {
+		t.Fatalf("LoadMetadata() error = %v", err)
+	}
+	if len(configs) != 2 {
+		t.Fatalf("LoadMetadata() configs = %v, want 2", len(configs))
+	}
+	if configs[0].IsPublic || aws.Int64Value(configs[0].TTL) != 60 {
+		t.Errorf("LoadMetadata() private config = %+v", configs[0])
+	}
+	if !configs[1].IsPublic || configs[1].HostedZoneID != "PUBLIC" || configs[1].RecordTTLs["foo.example.com"] != 30 {
+		t.Errorf("LoadMetadata() public config = %+v", configs[1])
+	}
+}
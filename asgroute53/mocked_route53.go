@@ -1,6 +1,14 @@
 package asgroute53
 
 import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/route53"
 	"github.com/aws/aws-sdk-go/service/route53/route53iface"
 )
@@ -9,32 +17,231 @@ type mockedRoute53 struct {
 	route53iface.Route53API
 	listResourceRecordSetsOutput   *route53.ListResourceRecordSetsOutput
 	listResourceRecordSetsError    error
+	resourceRecordSets             []*route53.ResourceRecordSet
+	listResourceRecordSetsPageSize int
+	listResourceRecordSetsCalls    int
 	getHostedZoneOutput            *route53.GetHostedZoneOutput
 	getHostedZoneError             error
+	hostedZones                    []*route53.HostedZone
+	hostedZoneVPCs                 map[string][]*route53.VPC
 	changeResourceRecordSetsOutput *route53.ChangeResourceRecordSetsOutput
 	changeResourceRecordSetError   error
+	changeResourceRecordSetErrors  map[string]error
+	changeResourceRecordSetQueue   []error
+	changeResourceRecordSetsInputs []*route53.ChangeResourceRecordSetsInput
+	// applyChanges makes successful change batches modify resourceRecordSets
+	applyChanges               bool
+	createHealthCheckOutput    *route53.CreateHealthCheckOutput
+	createHealthCheckInputs    []*route53.CreateHealthCheckInput
+	createHealthCheckError     error
+	changeTagsForResourceError error
+	deleteHealthCheckError     error
+	deletedHealthCheckIDs      []string
+	getChangeStatuses          []string
+	getChangeError             error
+	getChangeCalls             int
+	getHostedZoneCalls         int
 }
 
 func (m *mockedRoute53) ListResourceRecordSets(input *route53.ListResourceRecordSetsInput) (*route53.ListResourceRecordSetsOutput, error) {
+	m.listResourceRecordSetsCalls++
 	if m.listResourceRecordSetsError != nil {
 		return nil, m.listResourceRecordSetsError
 	}
 
-	return m.listResourceRecordSetsOutput, nil
+	if m.resourceRecordSets == nil {
+		return m.listResourceRecordSetsOutput, nil
+	}
+
+	return m.listResourceRecordSetsPage(input), nil
+}
+
+// listResourceRecordSetsPage pages through resourceRecordSets, which must be sorted the way Route 53 sorts them
+func (m *mockedRoute53) listResourceRecordSetsPage(input *route53.ListResourceRecordSetsInput) *route53.ListResourceRecordSetsOutput {
+	pageSize := m.listResourceRecordSetsPageSize
+	if pageSize == 0 {
+		pageSize = 100
+	}
+
+	start := len(m.resourceRecordSets)
+	for i, recordSet := range m.resourceRecordSets {
+		if compareRecordSetPosition(recordSet, input) >= 0 {
+			start = i
+			break
+		}
+	}
+
+	end := start + pageSize
+	if end >= len(m.resourceRecordSets) {
+		return &route53.ListResourceRecordSetsOutput{
+			ResourceRecordSets: m.resourceRecordSets[start:],
+			IsTruncated:        aws.Bool(false),
+		}
+	}
+
+	next := m.resourceRecordSets[end]
+	return &route53.ListResourceRecordSetsOutput{
+		ResourceRecordSets:   m.resourceRecordSets[start:end],
+		IsTruncated:          aws.Bool(true),
+		NextRecordName:       next.Name,
+		NextRecordType:       next.Type,
+		NextRecordIdentifier: next.SetIdentifier,
+	}
+}
+
+func compareRecordSetPosition(recordSet *route53.ResourceRecordSet, input *route53.ListResourceRecordSetsInput) int {
+	if c := strings.Compare(reverseLabels(aws.StringValue(recordSet.Name)), reverseLabels(aws.StringValue(input.StartRecordName))); c != 0 {
+		return c
+	}
+	if c := strings.Compare(aws.StringValue(recordSet.Type), aws.StringValue(input.StartRecordType)); c != 0 {
+		return c
+	}
+
+	return strings.Compare(aws.StringValue(recordSet.SetIdentifier), aws.StringValue(input.StartRecordIdentifier))
+}
+
+func reverseLabels(name string) string {
+	labels := strings.Split(normalizeRecordName(name), ".")
+	for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+		labels[i], labels[j] = labels[j], labels[i]
+	}
+
+	return strings.Join(labels, ".")
 }
 
 func (m *mockedRoute53) GetHostedZone(input *route53.GetHostedZoneInput) (*route53.GetHostedZoneOutput, error) {
+	m.getHostedZoneCalls++
 	if m.getHostedZoneError != nil {
 		return nil, m.getHostedZoneError
 	}
 
+	if m.hostedZones != nil {
+		for _, hostedZone := range m.hostedZones {
+			if *hostedZone.Id == *input.Id {
+				return &route53.GetHostedZoneOutput{
+					HostedZone: hostedZone,
+					VPCs:       m.hostedZoneVPCs[*hostedZone.Id],
+				}, nil
+			}
+		}
+		return nil, awserr.New(route53.ErrCodeNoSuchHostedZone, "no such hosted zone", nil)
+	}
+
 	return m.getHostedZoneOutput, nil
 }
 
+// ListHostedZonesByName returns the hosted zones named exactly like the requested name, which is
+// all the loader looks at
+func (m *mockedRoute53) ListHostedZonesByName(input *route53.ListHostedZonesByNameInput) (*route53.ListHostedZonesByNameOutput, error) {
+	output := &route53.ListHostedZonesByNameOutput{
+		IsTruncated: aws.Bool(false),
+	}
+	for _, hostedZone := range m.hostedZones {
+		if normalizeRecordName(*hostedZone.Name) == normalizeRecordName(*input.DNSName) {
+			output.HostedZones = append(output.HostedZones, hostedZone)
+		}
+	}
+
+	return output, nil
+}
+
 func (m *mockedRoute53) ChangeResourceRecordSets(input *route53.ChangeResourceRecordSetsInput) (*route53.ChangeResourceRecordSetsOutput, error) {
+	m.changeResourceRecordSetsInputs = append(m.changeResourceRecordSetsInputs, input)
+	if len(m.changeResourceRecordSetQueue) > 0 {
+		err := m.changeResourceRecordSetQueue[0]
+		m.changeResourceRecordSetQueue = m.changeResourceRecordSetQueue[1:]
+		if err != nil {
+			return nil, err
+		}
+	}
 	if m.changeResourceRecordSetError != nil {
 		return nil, m.changeResourceRecordSetError
 	}
+	if err := m.changeResourceRecordSetErrors[*input.HostedZoneId]; err != nil {
+		return nil, err
+	}
+
+	if m.applyChanges {
+		m.applyChangeBatch(input.ChangeBatch)
+	}
 
 	return m.changeResourceRecordSetsOutput, nil
 }
+
+// applyChangeBatch replaces or removes the changed record sets, keeping them sorted like Route 53 does
+func (m *mockedRoute53) applyChangeBatch(changeBatch *route53.ChangeBatch) {
+	for _, change := range changeBatch.Changes {
+		var recordSets []*route53.ResourceRecordSet
+		for _, recordSet := range m.resourceRecordSets {
+			if recordSetKey(recordSet) != recordSetKey(change.ResourceRecordSet) {
+				recordSets = append(recordSets, recordSet)
+			}
+		}
+		if *change.Action != route53.ChangeActionDelete {
+			recordSets = append(recordSets, change.ResourceRecordSet)
+		}
+		m.resourceRecordSets = recordSets
+	}
+
+	sort.SliceStable(m.resourceRecordSets, func(i, j int) bool {
+		return compareRecordSetPosition(m.resourceRecordSets[i], &route53.ListResourceRecordSetsInput{
+			StartRecordName:       m.resourceRecordSets[j].Name,
+			StartRecordType:       m.resourceRecordSets[j].Type,
+			StartRecordIdentifier: m.resourceRecordSets[j].SetIdentifier,
+		}) < 0
+	})
+}
+
+func (m *mockedRoute53) CreateHealthCheck(input *route53.CreateHealthCheckInput) (*route53.CreateHealthCheckOutput, error) {
+	m.createHealthCheckInputs = append(m.createHealthCheckInputs, input)
+	if m.createHealthCheckError != nil {
+		return nil, m.createHealthCheckError
+	}
+
+	if m.createHealthCheckOutput == nil {
+		return &route53.CreateHealthCheckOutput{
+			HealthCheck: &route53.HealthCheck{
+				Id: aws.String(fmt.Sprintf("health-check-%d", len(m.createHealthCheckInputs))),
+			},
+		}, nil
+	}
+
+	return m.createHealthCheckOutput, nil
+}
+
+func (m *mockedRoute53) ChangeTagsForResource(input *route53.ChangeTagsForResourceInput) (*route53.ChangeTagsForResourceOutput, error) {
+	if m.changeTagsForResourceError != nil {
+		return nil, m.changeTagsForResourceError
+	}
+
+	return &route53.ChangeTagsForResourceOutput{}, nil
+}
+
+func (m *mockedRoute53) DeleteHealthCheck(input *route53.DeleteHealthCheckInput) (*route53.DeleteHealthCheckOutput, error) {
+	if m.deleteHealthCheckError != nil {
+		return nil, m.deleteHealthCheckError
+	}
+
+	m.deletedHealthCheckIDs = append(m.deletedHealthCheckIDs, *input.HealthCheckId)
+	return &route53.DeleteHealthCheckOutput{}, nil
+}
+
+func (m *mockedRoute53) GetChangeWithContext(ctx context.Context, input *route53.GetChangeInput, opts ...request.Option) (*route53.GetChangeOutput, error) {
+	m.getChangeCalls++
+	if m.getChangeError != nil {
+		return nil, m.getChangeError
+	}
+
+	status := route53.ChangeStatusInsync
+	if len(m.getChangeStatuses) > 0 {
+		status = m.getChangeStatuses[0]
+		m.getChangeStatuses = m.getChangeStatuses[1:]
+	}
+
+	return &route53.GetChangeOutput{
+		ChangeInfo: &route53.ChangeInfo{
+			Id:     input.Id,
+			Status: aws.String(status),
+		},
+	}, nil
+}
@@ -0,0 +1,152 @@
+package asgroute53
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// ASGNameTagKey is tagged on instances by Auto Scaling, possibly after the launching hook fired
+const ASGNameTagKey = "aws:autoscaling:groupName"
+
+// instanceIDTemplate is used as set identifier when templated records are configured without one
+const instanceIDTemplate = "{instance-id}"
+
+var placeholderPattern = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// hasPlaceholders returns true if the value contains at least one placeholder
+func hasPlaceholders(value string) bool {
+	return placeholderPattern.MatchString(value)
+}
+
+// hasInstancePlaceholder returns true if the value contains a placeholder that is unique to each
+// instance, so that no two running instances expand it to the same name
+func hasInstancePlaceholder(value string) bool {
+	for _, match := range placeholderPattern.FindAllStringSubmatch(value, -1) {
+		switch match[1] {
+		case "instance-id", "private-ip-dashed", "public-ip-dashed":
+			return true
+		}
+	}
+
+	return false
+}
+
+// validateTemplate checks that every placeholder in the template is supported
+func validateTemplate(template string) error {
+	for _, match := range placeholderPattern.FindAllStringSubmatch(template, -1) {
+		if !isKnownPlaceholder(match[1]) {
+			return fmt.Errorf("unsupported placeholder %s in %s", match[0], template)
+		}
+	}
+
+	return nil
+}
+
+func isKnownPlaceholder(name string) bool {
+	switch name {
+	case "instance-id", "az", "private-ip-dashed", "public-ip-dashed", "asg":
+		return true
+	}
+
+	return strings.HasPrefix(name, "tag:") && len(name) > len("tag:")
+}
+
+// expandTemplate replaces placeholders in the template with values of the EC2 instance
+func expandTemplate(template string, ec2Instance *ec2.Instance) (string, error) {
+	var expandErr error
+	expanded := placeholderPattern.ReplaceAllStringFunc(template, func(placeholder string) string {
+		value := getPlaceholderValue(placeholder[1:len(placeholder)-1], ec2Instance)
+		if value == nil || *value == "" {
+			if expandErr == nil {
+				expandErr = fmt.Errorf("placeholder %s has no value for instance %s", placeholder, aws.StringValue(ec2Instance.InstanceId))
+			}
+			return placeholder
+		}
+
+		return *value
+	})
+
+	if expandErr != nil {
+		return "", expandErr
+	}
+
+	return expanded, nil
+}
+
+func getPlaceholderValue(name string, ec2Instance *ec2.Instance) *string {
+	switch name {
+	case "instance-id":
+		return ec2Instance.InstanceId
+	case "az":
+		if ec2Instance.Placement == nil {
+			return nil
+		}
+		return ec2Instance.Placement.AvailabilityZone
+	case "private-ip-dashed":
+		return dashed(ec2Instance.PrivateIpAddress)
+	case "public-ip-dashed":
+		return dashed(ec2Instance.PublicIpAddress)
+	case "asg":
+		return findTagValue(ec2Instance.Tags, ASGNameTagKey)
+	}
+
+	if strings.HasPrefix(name, "tag:") {
+		return findTagValue(ec2Instance.Tags, strings.TrimPrefix(name, "tag:"))
+	}
+
+	return nil
+}
+
+func dashed(ipAddress *string) *string {
+	if ipAddress == nil {
+		return nil
+	}
+
+	return aws.String(strings.NewReplacer(".", "-", ":", "-").Replace(*ipAddress))
+}
+
+func findTagValue(tags []*ec2.Tag, key string) *string {
+	for _, tag := range tags {
+		if aws.StringValue(tag.Key) == key {
+			return tag.Value
+		}
+	}
+
+	return nil
+}
+
+// forInstance returns a copy of the config with placeholders in record names and set identifier
+// expanded for the EC2 instance
+func (c *Route53ZoneConfig) forInstance(ec2Instance *ec2.Instance) (*Route53ZoneConfig, error) {
+	expanded := *c
+	expanded.DNSRecords = make([]string, len(c.DNSRecords))
+	expanded.RecordTTLs = nil
+	for i, record := range c.DNSRecords {
+		name, err := expandTemplate(record, ec2Instance)
+		if err != nil {
+			return nil, err
+		}
+		expanded.DNSRecords[i] = name
+
+		if recordTTL, ok := c.RecordTTLs[record]; ok {
+			if expanded.RecordTTLs == nil {
+				expanded.RecordTTLs = map[string]int64{}
+			}
+			expanded.RecordTTLs[name] = recordTTL
+		}
+	}
+
+	if c.SetIdentifier != nil {
+		setIdentifier, err := expandTemplate(*c.SetIdentifier, ec2Instance)
+		if err != nil {
+			return nil, err
+		}
+		expanded.SetIdentifier = aws.String(setIdentifier)
+	}
+
+	return &expanded, nil
+}
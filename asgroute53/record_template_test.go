@@ -0,0 +1,133 @@
+package asgroute53
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+func Test_expandTemplate(t *testing.T) {
+	instance := &ec2.Instance{
+		InstanceId:       aws.String("i-0abc"),
+		PrivateIpAddress: aws.String("10.0.1.23"),
+		Placement: &ec2.Placement{
+			AvailabilityZone: aws.String("ap-northeast-1a"),
+		},
+		Tags: []*ec2.Tag{
+			{
+				Key:   aws.String(ASGNameTagKey),
+				Value: aws.String("web"),
+			},
+			{
+				Key:   aws.String("Name"),
+				Value: aws.String("web-node"),
+			},
+		},
+	}
+	tests := []struct {
+		name     string
+		template string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "plain",
+			template: "pool.example.com",
+			want:     "pool.example.com",
+			wantErr:  false,
+		},
+		{
+			name:     "instance-id",
+			template: "{instance-id}.nodes.internal.example.com",
+			want:     "i-0abc.nodes.internal.example.com",
+			wantErr:  false,
+		},
+		{
+			name:     "multiple",
+			template: "{tag:Name}-{private-ip-dashed}.{az}.{asg}.example.com",
+			want:     "web-node-10-0-1-23.ap-northeast-1a.web.example.com",
+			wantErr:  false,
+		},
+		{
+			name:     "missing-value",
+			template: "{public-ip-dashed}.example.com",
+			wantErr:  true,
+		},
+		{
+			name:     "missing-tag",
+			template: "{tag:Role}.example.com",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := expandTemplate(tt.template, instance)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("expandTemplate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("expandTemplate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_validateTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		wantErr  bool
+	}{
+		{
+			name:     "known",
+			template: "{instance-id}.{az}.{asg}.{tag:Name}.example.com",
+			wantErr:  false,
+		},
+		{
+			name:     "unknown",
+			template: "{hostname}.example.com",
+			wantErr:  true,
+		},
+		{
+			name:     "empty-tag",
+			template: "{tag:}.example.com",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateTemplate(tt.template); (err != nil) != tt.wantErr {
+				t.Errorf("validateTemplate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRoute53ZoneConfig_forInstance(t *testing.T) {
+	config := &Route53ZoneConfig{
+		HostedZoneID:  "ID",
+		DNSRecords:    []string{"pool.example.com", "{instance-id}.nodes.example.com"},
+		SetIdentifier: aws.String(instanceIDTemplate),
+	}
+	got, err := config.forInstance(&ec2.Instance{
+		InstanceId: aws.String("i-0abc"),
+	})
+	if err != nil {
+		t.Fatalf("Route53ZoneConfig.forInstance() error = %v", err)
+	}
+
+	want := &Route53ZoneConfig{
+		HostedZoneID:  "ID",
+		DNSRecords:    []string{"pool.example.com", "i-0abc.nodes.example.com"},
+		SetIdentifier: aws.String("i-0abc"),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Route53ZoneConfig.forInstance() = %v, want %v", got, want)
+	}
+	if config.DNSRecords[1] != "{instance-id}.nodes.example.com" {
+		t.Errorf("Route53ZoneConfig.forInstance() modified the original config")
+	}
+}
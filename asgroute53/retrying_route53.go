@@ -0,0 +1,247 @@
+package asgroute53
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/route53/route53iface"
+)
+
+const (
+	// route53RequestsPerSecond matches the Route 53 API limit of five requests per second per account
+	route53RequestsPerSecond = 5
+	maxRetryAttempts         = 8
+	baseRetryDelay           = 200 * time.Millisecond
+	maxRetryDelay            = 10 * time.Second
+)
+
+type (
+	// retryingRoute53 wraps a Route 53 client, limiting the request rate and retrying throttled or
+	// otherwise transient errors with jittered exponential backoff until the invocation deadline
+	retryingRoute53 struct {
+		route53iface.Route53API
+		ctx      context.Context
+		limiter  *tokenBucket
+		attempts int
+		sleep    func(ctx context.Context, d time.Duration) error
+	}
+	// tokenBucket is a token bucket rate limiter shared by all calls made through a client
+	tokenBucket struct {
+		mu         sync.Mutex
+		tokens     float64
+		capacity   float64
+		rate       float64
+		lastRefill time.Time
+	}
+)
+
+// NewRetryingClient wraps a Route 53 client for one invocation. Calls share a rate limiter and are
+// retried on throttling and transient errors, stopping shortly before the deadline of ctx.
+func NewRetryingClient(ctx context.Context, client route53iface.Route53API) route53iface.Route53API {
+	return &retryingRoute53{
+		Route53API: client,
+		ctx:        ctx,
+		limiter:    newTokenBucket(route53RequestsPerSecond, route53RequestsPerSecond),
+		attempts:   maxRetryAttempts,
+		sleep:      sleepContext,
+	}
+}
+
+func newTokenBucket(rate float64, capacity float64) *tokenBucket {
+	return &tokenBucket{
+		tokens:     capacity,
+		capacity:   capacity,
+		rate:       rate,
+		lastRefill: time.Now(),
+	}
+}
+
+// Wait blocks until a token is available or ctx is done
+func (b *tokenBucket) Wait(ctx context.Context) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		b.mu.Lock()
+		now := time.Now()
+		b.tokens += now.Sub(b.lastRefill).Seconds() * b.rate
+		if b.tokens > b.capacity {
+			b.tokens = b.capacity
+		}
+		b.lastRefill = now
+
+		if b.tokens >= 1 {
+			b.tokens--
+			b.mu.Unlock()
+			return nil
+		}
+
+		wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+		b.mu.Unlock()
+
+		if err := sleepContext(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
+
+// isRetryable returns true for throttling and transient errors of the Route 53 API
+func isRetryable(err error) bool {
+	var awsErr awserr.Error
+	if !errors.As(err, &awsErr) {
+		return false
+	}
+
+	switch awsErr.Code() {
+	case "Throttling", route53.ErrCodeThrottlingException, "PriorRequestNotFound",
+		"ServiceUnavailable", "InternalFailure", "InternalError":
+		return true
+	case request.ErrCodeRequestError, request.ErrCodeResponseTimeout:
+		return request.IsErrorRetryable(err)
+	}
+
+	var requestFailure awserr.RequestFailure
+	if errors.As(err, &requestFailure) && requestFailure.StatusCode() >= 500 {
+		return true
+	}
+
+	return request.IsErrorThrottle(err)
+}
+
+// backoff returns a delay chosen uniformly up to an exponentially growing bound
+func backoff(attempt int) time.Duration {
+	bound := baseRetryDelay << uint(attempt)
+	if bound <= 0 || bound > maxRetryDelay {
+		bound = maxRetryDelay
+	}
+
+	return time.Duration(rand.Int63n(int64(bound)))
+}
+
+func (c *retryingRoute53) do(ctx context.Context, operation string, call func() error) error {
+	if deadline, ok := ctx.Deadline(); ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline.Add(-DeadlineMargin))
+		defer cancel()
+	}
+
+	var err error
+	for attempt := 0; attempt < c.attempts; attempt++ {
+		if waitErr := c.limiter.Wait(ctx); waitErr != nil {
+			if err == nil {
+				err = waitErr
+			}
+			return err
+		}
+
+		err = call()
+		if err == nil || !isRetryable(err) {
+			return err
+		}
+
+		delay := backoff(attempt)
+		fmt.Printf("%s failed with a retryable error, retrying in %s: %s\n", operation, delay, err)
+		if c.sleep(ctx, delay) != nil {
+			return err
+		}
+	}
+
+	return err
+}
+
+func (c *retryingRoute53) ListResourceRecordSets(input *route53.ListResourceRecordSetsInput) (*route53.ListResourceRecordSetsOutput, error) {
+	var output *route53.ListResourceRecordSetsOutput
+	err := c.do(c.ctx, "ListResourceRecordSets", func() (err error) {
+		output, err = c.Route53API.ListResourceRecordSets(input)
+		return err
+	})
+
+	return output, err
+}
+
+func (c *retryingRoute53) ChangeResourceRecordSets(input *route53.ChangeResourceRecordSetsInput) (*route53.ChangeResourceRecordSetsOutput, error) {
+	var output *route53.ChangeResourceRecordSetsOutput
+	err := c.do(c.ctx, "ChangeResourceRecordSets", func() (err error) {
+		output, err = c.Route53API.ChangeResourceRecordSets(input)
+		return err
+	})
+
+	return output, err
+}
+
+func (c *retryingRoute53) GetHostedZone(input *route53.GetHostedZoneInput) (*route53.GetHostedZoneOutput, error) {
+	var output *route53.GetHostedZoneOutput
+	err := c.do(c.ctx, "GetHostedZone", func() (err error) {
+		output, err = c.Route53API.GetHostedZone(input)
+		return err
+	})
+
+	return output, err
+}
+
+func (c *retryingRoute53) ListHostedZonesByName(input *route53.ListHostedZonesByNameInput) (*route53.ListHostedZonesByNameOutput, error) {
+	var output *route53.ListHostedZonesByNameOutput
+	err := c.do(c.ctx, "ListHostedZonesByName", func() (err error) {
+		output, err = c.Route53API.ListHostedZonesByName(input)
+		return err
+	})
+
+	return output, err
+}
+
+func (c *retryingRoute53) GetChangeWithContext(ctx context.Context, input *route53.GetChangeInput, opts ...request.Option) (*route53.GetChangeOutput, error) {
+	var output *route53.GetChangeOutput
+	err := c.do(ctx, "GetChange", func() (err error) {
+		output, err = c.Route53API.GetChangeWithContext(ctx, input, opts...)
+		return err
+	})
+
+	return output, err
+}
+
+func (c *retryingRoute53) CreateHealthCheck(input *route53.CreateHealthCheckInput) (*route53.CreateHealthCheckOutput, error) {
+	var output *route53.CreateHealthCheckOutput
+	err := c.do(c.ctx, "CreateHealthCheck", func() (err error) {
+		output, err = c.Route53API.CreateHealthCheck(input)
+		return err
+	})
+
+	return output, err
+}
+
+func (c *retryingRoute53) ChangeTagsForResource(input *route53.ChangeTagsForResourceInput) (*route53.ChangeTagsForResourceOutput, error) {
+	var output *route53.ChangeTagsForResourceOutput
+	err := c.do(c.ctx, "ChangeTagsForResource", func() (err error) {
+		output, err = c.Route53API.ChangeTagsForResource(input)
+		return err
+	})
+
+	return output, err
+}
+
+func (c *retryingRoute53) DeleteHealthCheck(input *route53.DeleteHealthCheckInput) (*route53.DeleteHealthCheckOutput, error) {
+	var output *route53.DeleteHealthCheckOutput
+	err := c.do(c.ctx, "DeleteHealthCheck", func() (err error) {
+		output, err = c.Route53API.DeleteHealthCheck(input)
+		return err
+	})
+
+	return output, err
+}
@@ -0,0 +1,149 @@
+package asgroute53
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/route53"
+)
+
+func Test_isRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "throttling",
+			err:  awserr.New("Throttling", "Rate exceeded", nil),
+			want: true,
+		},
+		{
+			name: "prior-request-not-found",
+			err:  awserr.New("PriorRequestNotFound", "", nil),
+			want: true,
+		},
+		{
+			name: "prior-request-not-complete",
+			err:  awserr.New(route53.ErrCodePriorRequestNotComplete, "", nil),
+			want: true,
+		},
+		{
+			name: "server-error",
+			err:  awserr.NewRequestFailure(awserr.New("Unknown", "", nil), 502, "request-id"),
+			want: true,
+		},
+		{
+			name: "invalid-change-batch",
+			err:  awserr.New(route53.ErrCodeInvalidChangeBatch, "", nil),
+			want: false,
+		},
+		{
+			name: "not-aws-error",
+			err:  errors.New("error"),
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRetryable(tt.err); got != tt.want {
+				t.Errorf("isRetryable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_retryingRoute53_ChangeResourceRecordSets(t *testing.T) {
+	throttling := awserr.New("Throttling", "Rate exceeded", nil)
+	tests := []struct {
+		name      string
+		errs      []error
+		timeout   time.Duration
+		wantCalls int
+		wantErr   bool
+	}{
+		{
+			name:      "success",
+			timeout:   time.Minute,
+			wantCalls: 1,
+			wantErr:   false,
+		},
+		{
+			name:      "retry-throttling",
+			errs:      []error{throttling, awserr.New("PriorRequestNotFound", "", nil)},
+			timeout:   time.Minute,
+			wantCalls: 3,
+			wantErr:   false,
+		},
+		{
+			name:      "not-retryable",
+			errs:      []error{awserr.New(route53.ErrCodeInvalidChangeBatch, "", nil)},
+			timeout:   time.Minute,
+			wantCalls: 1,
+			wantErr:   true,
+		},
+		{
+			name:      "attempts-exhausted",
+			errs:      []error{throttling, throttling, throttling, throttling},
+			timeout:   time.Minute,
+			wantCalls: 3,
+			wantErr:   true,
+		},
+		{
+			name:      "deadline",
+			errs:      []error{throttling, throttling},
+			timeout:   DeadlineMargin,
+			wantCalls: 0,
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+
+			m := &mockedRoute53{
+				changeResourceRecordSetsOutput: &route53.ChangeResourceRecordSetsOutput{},
+				changeResourceRecordSetQueue:   tt.errs,
+			}
+			c := NewRetryingClient(ctx, m).(*retryingRoute53)
+			c.attempts = 3
+			c.sleep = func(ctx context.Context, d time.Duration) error {
+				return ctx.Err()
+			}
+
+			_, err := c.ChangeResourceRecordSets(&route53.ChangeResourceRecordSetsInput{
+				HostedZoneId: aws.String("ZONE"),
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ChangeResourceRecordSets() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := len(m.changeResourceRecordSetsInputs); got != tt.wantCalls {
+				t.Errorf("ChangeResourceRecordSets() calls = %v, want %v", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func Test_tokenBucket_Wait(t *testing.T) {
+	b := newTokenBucket(1000, 2)
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		if err := b.Wait(context.Background()); err != nil {
+			t.Fatalf("Wait() error = %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < time.Millisecond {
+		t.Errorf("Wait() elapsed = %v, want at least 1ms", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := b.Wait(ctx); err == nil {
+		t.Errorf("Wait() error = nil, want context error")
+	}
+}
@@ -0,0 +1,189 @@
+package asgroute53
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/route53"
+)
+
+// loadReverseZone reads the optional reverse hosted zone of private records. PTR records point at
+// addresses, so they cannot be used with CNAME records.
+func (l Route53ZoneConfigLoader) loadReverseZone(tags *[]*ec2.Tag, keys zoneTagKeys, config *Route53ZoneConfig) error {
+	if config.IsPublic || keys.reverseHostedZoneID == "" {
+		return nil
+	}
+
+	config.ReverseHostedZoneID = l.findValueFromEC2Tags(tags, keys.reverseHostedZoneID)
+	if config.ReverseHostedZoneID == nil {
+		return nil
+	}
+
+	if *config.ReverseHostedZoneID == "" {
+		return fmt.Errorf("%s should not be empty", keys.reverseHostedZoneID)
+	}
+
+	if config.RecordType == RecordTypeCNAME {
+		return fmt.Errorf("%s cannot be used with %s record type", keys.reverseHostedZoneID, RecordTypeCNAME)
+	}
+
+	return nil
+}
+
+// setReverseZoneName sets the name of the reverse zone, checking that it can hold the PTR records
+// of at least one address family of the records
+func (c *Route53ZoneConfig) setReverseZoneName(zoneName string) error {
+	for _, recordType := range c.RecordTypes() {
+		suffix := "in-addr.arpa"
+		if recordType == "AAAA" {
+			suffix = "ip6.arpa"
+		}
+		if isInZone(suffix, zoneName) || isInZone(zoneName, suffix) {
+			c.ReverseZoneName = zoneName
+			return nil
+		}
+	}
+
+	return fmt.Errorf("reverse hosted zone %s (%s) cannot hold PTR records of %s addresses",
+		aws.StringValue(c.ReverseHostedZoneID), zoneName, c.AddressFamily)
+}
+
+// isInZone returns true if the name is the zone name or a subdomain of it
+func isInZone(name string, zoneName string) bool {
+	name = normalizeRecordName(name)
+	zoneName = normalizeRecordName(zoneName)
+
+	return zoneName == "." || name == zoneName || strings.HasSuffix(name, "."+zoneName)
+}
+
+// reverseName returns the in-addr.arpa or ip6.arpa name of an IP address
+func reverseName(address string) (string, error) {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return "", fmt.Errorf("invalid IP address: %s", address)
+	}
+
+	if ipv4 := ip.To4(); ipv4 != nil {
+		return fmt.Sprintf("%d.%d.%d.%d.in-addr.arpa", ipv4[3], ipv4[2], ipv4[1], ipv4[0]), nil
+	}
+
+	const hexDigits = "0123456789abcdef"
+	labels := make([]string, 0, 2*net.IPv6len)
+	for i := net.IPv6len - 1; i >= 0; i-- {
+		labels = append(labels, string(hexDigits[ip[i]&0x0f]), string(hexDigits[ip[i]>>4]))
+	}
+
+	return strings.Join(labels, ".") + ".ip6.arpa", nil
+}
+
+// getReverseNames returns the reverse names of the addresses the records of the instance point at.
+// Names outside the reverse zone, such as the ip6.arpa names of dual-stack instances in an
+// in-addr.arpa zone, are left out since Route 53 rejects them.
+func (r *ASGRoute53) getReverseNames(config *Route53ZoneConfig, ec2Instance *ec2.Instance) ([]string, error) {
+	resourceRecords, err := r.getResourceRecords(config, ec2Instance)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, recordType := range config.RecordTypes() {
+		for _, resourceRecord := range resourceRecords[recordType] {
+			name, err := reverseName(aws.StringValue(resourceRecord.Value))
+			if err != nil {
+				return nil, err
+			}
+			if isInZone(name, config.ReverseZoneName) {
+				names = append(names, name)
+			}
+		}
+	}
+
+	return names, nil
+}
+
+// getReverseUpsertChangeGroup builds the changes pointing PTR records of the instance addresses at
+// its first record, each with a TXT ownership record like the forward records
+func (r *ASGRoute53) getReverseUpsertChangeGroup(config *Route53ZoneConfig, ec2Instance *ec2.Instance) (*changeGroup, error) {
+	names, err := r.getReverseNames(config, ec2Instance)
+	if err != nil {
+		return nil, err
+	}
+
+	group := &changeGroup{
+		hostedZoneID: *config.ReverseHostedZoneID,
+	}
+
+	target := config.DNSRecords[0]
+	ttl := aws.Int64(config.TTLFor(target))
+	for _, name := range names {
+		group.changes = append(group.changes,
+			&route53.Change{
+				Action: aws.String("UPSERT"),
+				ResourceRecordSet: &route53.ResourceRecordSet{
+					Name:            aws.String(name),
+					Type:            aws.String("TXT"),
+					ResourceRecords: toResourceRecords(formatValues("TXT", []string{*ec2Instance.InstanceId})),
+					TTL:             ttl,
+				},
+			},
+			&route53.Change{
+				Action: aws.String("UPSERT"),
+				ResourceRecordSet: &route53.ResourceRecordSet{
+					Name:            aws.String(name),
+					Type:            aws.String("PTR"),
+					ResourceRecords: toResourceRecords([]string{target}),
+					TTL:             ttl,
+				},
+			})
+	}
+
+	return group, nil
+}
+
+// getReverseDeleteChangeGroup builds the changes deleting PTR records of the instance addresses
+// whose TXT ownership record lists the instance
+func (r *ASGRoute53) getReverseDeleteChangeGroup(config *Route53ZoneConfig, ec2Instance *ec2.Instance) (*changeGroup, error) {
+	names, err := r.getReverseNames(config, ec2Instance)
+	if err != nil {
+		return nil, err
+	}
+
+	reverseConfig := &Route53ZoneConfig{
+		HostedZoneID: *config.ReverseHostedZoneID,
+	}
+	group := &changeGroup{
+		hostedZoneID: *config.ReverseHostedZoneID,
+	}
+
+	instanceID := *ec2Instance.InstanceId
+	for _, name := range names {
+		txtRecordSet, err := r.getOwnedTXTRecordSet(reverseConfig, name, instanceID)
+		if err != nil {
+			return nil, err
+		}
+		if txtRecordSet == nil {
+			fmt.Printf("Skipping %s, TXT record is not owned by %s\n", name, instanceID)
+			continue
+		}
+
+		recordSet, err := r.getRecordSet(*config.ReverseHostedZoneID, name, "PTR", nil)
+		if err != nil {
+			return nil, err
+		}
+
+		group.changes = append(group.changes,
+			&route53.Change{
+				Action:            aws.String("DELETE"),
+				ResourceRecordSet: txtRecordSet,
+			},
+			&route53.Change{
+				Action:            aws.String("DELETE"),
+				ResourceRecordSet: recordSet,
+			})
+	}
+
+	return group, nil
+}
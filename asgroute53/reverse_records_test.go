@@ -0,0 +1,298 @@
+package asgroute53
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/route53"
+)
+
+func Test_reverseName(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "ipv4",
+			address: "10.1.2.3",
+			want:    "3.2.1.10.in-addr.arpa",
+			wantErr: false,
+		},
+		{
+			name:    "ipv6",
+			address: "2001:db8::567:89ab",
+			want:    "b.a.9.8.7.6.5.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa",
+			wantErr: false,
+		},
+		{
+			name:    "invalid",
+			address: "ip-10-1-2-3",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := reverseName(tt.address)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("reverseName() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("reverseName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChangeBatch_reverseRecords(t *testing.T) {
+	config := &Route53ZoneConfig{
+		HostedZoneID:        "ID",
+		DNSRecords:          []string{"{instance-id}.nodes.example.com", "pool.example.com"},
+		AddressFamily:       AddressFamilyDual,
+		RoutingPolicy:       RoutingPolicySimple,
+		ReverseHostedZoneID: aws.String("REVERSE-ID"),
+		ReverseZoneName:     "10.in-addr.arpa.",
+	}
+	instance := &ec2.Instance{
+		InstanceId:       aws.String("i-1"),
+		PrivateIpAddress: aws.String("10.1.2.3"),
+		NetworkInterfaces: []*ec2.InstanceNetworkInterface{
+			{
+				Attachment:    &ec2.InstanceNetworkInterfaceAttachment{DeviceIndex: aws.Int64(0)},
+				Ipv6Addresses: []*ec2.InstanceIpv6Address{{Ipv6Address: aws.String("2001:db8::1")}},
+			},
+		},
+	}
+	reverseRecordSets := func(owner string) []*route53.ResourceRecordSet {
+		return []*route53.ResourceRecordSet{
+			{
+				Name:            aws.String("3.2.1.10.in-addr.arpa."),
+				Type:            aws.String("PTR"),
+				ResourceRecords: toResourceRecords([]string{"i-1.nodes.example.com"}),
+				TTL:             aws.Int64(defaultTTL),
+			},
+			{
+				Name:            aws.String("3.2.1.10.in-addr.arpa."),
+				Type:            aws.String("TXT"),
+				ResourceRecords: toResourceRecords(formatValues("TXT", []string{owner})),
+				TTL:             aws.Int64(defaultTTL),
+			},
+		}
+	}
+	tests := []struct {
+		name        string
+		m           *mockedRoute53
+		upsert      bool
+		wantChanges []string
+	}{
+		{
+			name: "upsert",
+			m: &mockedRoute53{
+				changeResourceRecordSetsOutput: &route53.ChangeResourceRecordSetsOutput{},
+			},
+			upsert:      true,
+			wantChanges: []string{"UPSERT TXT 3.2.1.10.in-addr.arpa", "UPSERT PTR 3.2.1.10.in-addr.arpa i-1.nodes.example.com"},
+		},
+		{
+			name: "delete-owned",
+			m: &mockedRoute53{
+				resourceRecordSets:             reverseRecordSets("i-1"),
+				changeResourceRecordSetsOutput: &route53.ChangeResourceRecordSetsOutput{},
+			},
+			upsert:      false,
+			wantChanges: []string{"DELETE TXT 3.2.1.10.in-addr.arpa.", "DELETE PTR 3.2.1.10.in-addr.arpa. i-1.nodes.example.com"},
+		},
+		{
+			name: "delete-not-owned",
+			m: &mockedRoute53{
+				resourceRecordSets:             reverseRecordSets("i-2"),
+				changeResourceRecordSetsOutput: &route53.ChangeResourceRecordSetsOutput{},
+			},
+			upsert:      false,
+			wantChanges: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			batch := New(tt.m).NewChangeBatch()
+			var err error
+			if tt.upsert {
+				err = batch.UpsertRecordSets("k", config, instance)
+			} else {
+				err = batch.DeleteRecordSets("k", config, instance)
+			}
+			if err != nil {
+				t.Fatalf("ChangeBatch error = %v", err)
+			}
+			if _, errs := batch.Submit(); errs["k"] != nil {
+				t.Fatalf("ChangeBatch.Submit() error = %v", errs["k"])
+			}
+
+			var changes []string
+			for _, input := range tt.m.changeResourceRecordSetsInputs {
+				if *input.HostedZoneId != "REVERSE-ID" {
+					continue
+				}
+				for _, change := range input.ChangeBatch.Changes {
+					description := *change.Action + " " + *change.ResourceRecordSet.Type + " " + *change.ResourceRecordSet.Name
+					if *change.ResourceRecordSet.Type == "PTR" {
+						description += " " + *change.ResourceRecordSet.ResourceRecords[0].Value
+					}
+					changes = append(changes, description)
+				}
+			}
+			if !reflect.DeepEqual(changes, tt.wantChanges) {
+				t.Errorf("ChangeBatch reverse changes = %v, want %v", changes, tt.wantChanges)
+			}
+		})
+	}
+}
+
+func TestChangeBatch_reverseRecords_duplicate(t *testing.T) {
+	newConfig := func(zoneID string, record string) *Route53ZoneConfig {
+		return &Route53ZoneConfig{
+			HostedZoneID:        zoneID,
+			DNSRecords:          []string{record},
+			RoutingPolicy:       RoutingPolicySimple,
+			ReverseHostedZoneID: aws.String("REVERSE-ID"),
+			ReverseZoneName:     "10.in-addr.arpa.",
+		}
+	}
+	configs := []*Route53ZoneConfig{
+		newConfig("ID-1", "{instance-id}.nodes.example.com"),
+		newConfig("ID-2", "{instance-id}.nodes.example.net"),
+	}
+	instance := &ec2.Instance{
+		InstanceId:       aws.String("i-1"),
+		PrivateIpAddress: aws.String("10.1.2.3"),
+	}
+	tests := []struct {
+		name        string
+		m           *mockedRoute53
+		upsert      bool
+		wantChanges []string
+	}{
+		{
+			name: "upsert",
+			m: &mockedRoute53{
+				changeResourceRecordSetsOutput: &route53.ChangeResourceRecordSetsOutput{},
+			},
+			upsert:      true,
+			wantChanges: []string{"UPSERT TXT 3.2.1.10.in-addr.arpa", "UPSERT PTR 3.2.1.10.in-addr.arpa"},
+		},
+		{
+			name: "delete",
+			m: &mockedRoute53{
+				resourceRecordSets: []*route53.ResourceRecordSet{
+					{
+						Name:            aws.String("3.2.1.10.in-addr.arpa."),
+						Type:            aws.String("PTR"),
+						ResourceRecords: toResourceRecords([]string{"i-1.nodes.example.com"}),
+						TTL:             aws.Int64(defaultTTL),
+					},
+					{
+						Name:            aws.String("3.2.1.10.in-addr.arpa."),
+						Type:            aws.String("TXT"),
+						ResourceRecords: toResourceRecords(formatValues("TXT", []string{"i-1"})),
+						TTL:             aws.Int64(defaultTTL),
+					},
+				},
+				changeResourceRecordSetsOutput: &route53.ChangeResourceRecordSetsOutput{},
+			},
+			upsert:      false,
+			wantChanges: []string{"DELETE TXT 3.2.1.10.in-addr.arpa.", "DELETE PTR 3.2.1.10.in-addr.arpa."},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			batch := New(tt.m).NewChangeBatch()
+			for _, config := range configs {
+				var err error
+				if tt.upsert {
+					err = batch.UpsertRecordSets("k", config, instance)
+				} else {
+					err = batch.DeleteRecordSets("k", config, instance)
+				}
+				if err != nil {
+					t.Fatalf("ChangeBatch error = %v", err)
+				}
+			}
+			if _, errs := batch.Submit(); errs["k"] != nil {
+				t.Fatalf("ChangeBatch.Submit() error = %v", errs["k"])
+			}
+
+			var changes []string
+			for _, input := range tt.m.changeResourceRecordSetsInputs {
+				if *input.HostedZoneId != "REVERSE-ID" {
+					continue
+				}
+				for _, change := range input.ChangeBatch.Changes {
+					changes = append(changes, *change.Action+" "+*change.ResourceRecordSet.Type+" "+*change.ResourceRecordSet.Name)
+					if *change.ResourceRecordSet.Type == "PTR" && *change.Action == "UPSERT" &&
+						*change.ResourceRecordSet.ResourceRecords[0].Value != "i-1.nodes.example.com" {
+						t.Errorf("ChangeBatch PTR target = %v, want the record of the first configuration",
+							*change.ResourceRecordSet.ResourceRecords[0].Value)
+					}
+				}
+			}
+			if !reflect.DeepEqual(changes, tt.wantChanges) {
+				t.Errorf("ChangeBatch reverse changes = %v, want %v", changes, tt.wantChanges)
+			}
+		})
+	}
+}
+
+func Test_loadReverseZone(t *testing.T) {
+	tags := &[]*ec2.Tag{
+		{Key: aws.String(privateReverseHostedZoneIDKey), Value: aws.String("REVERSE-ID")},
+	}
+	l := NewZoneConfigLoader(&mockedRoute53{})
+
+	config := &Route53ZoneConfig{}
+	if err := l.loadReverseZone(tags, privateZoneTagKeys, config); err != nil || aws.StringValue(config.ReverseHostedZoneID) != "REVERSE-ID" {
+		t.Errorf("loadReverseZone() = %v, error %v", aws.StringValue(config.ReverseHostedZoneID), err)
+	}
+
+	config = &Route53ZoneConfig{IsPublic: true}
+	if err := l.loadReverseZone(tags, publicZoneTagKeys, config); err != nil || config.ReverseHostedZoneID != nil {
+		t.Errorf("loadReverseZone() public = %v, error %v", aws.StringValue(config.ReverseHostedZoneID), err)
+	}
+
+	config = &Route53ZoneConfig{RecordType: RecordTypeCNAME}
+	if err := l.loadReverseZone(tags, privateZoneTagKeys, config); err == nil {
+		t.Errorf("loadReverseZone() cname error = nil, want error")
+	}
+}
+
+func TestRoute53ZoneConfig_setReverseZoneName(t *testing.T) {
+	tests := []struct {
+		name          string
+		addressFamily string
+		zoneName      string
+		wantErr       bool
+	}{
+		{"ipv4", AddressFamilyIPv4, "10.in-addr.arpa.", false},
+		{"ipv4-arpa", AddressFamilyIPv4, "arpa.", false},
+		{"ipv6", AddressFamilyIPv6, "8.b.d.0.1.0.0.2.ip6.arpa.", false},
+		{"dual-ipv4-zone", AddressFamilyDual, "10.in-addr.arpa.", false},
+		{"ipv6-ipv4-zone", AddressFamilyIPv6, "10.in-addr.arpa.", true},
+		{"ipv4-ipv6-zone", AddressFamilyIPv4, "ip6.arpa.", true},
+		{"forward-zone", AddressFamilyIPv4, "example.com.", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Route53ZoneConfig{
+				AddressFamily:       tt.addressFamily,
+				ReverseHostedZoneID: aws.String("REVERSE-ID"),
+			}
+			err := config.setReverseZoneName(tt.zoneName)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Route53ZoneConfig.setReverseZoneName() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,251 @@
+package asgroute53
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/route53"
+)
+
+// maxConflictRetries is the number of times a shared record update is retried when another
+// invocation changed the records between reading and writing them
+const maxConflictRetries = 5
+
+// updateSharedRecordSets adds or removes an instance from record sets shared by every instance
+// in the pool. Current record sets are deleted and recreated in the same change batch, so Route 53
+// rejects the batch if another invocation modified them in the meantime. It returns the ID of the
+// applied change, or nil if nothing had to be changed.
+func (r *ASGRoute53) updateSharedRecordSets(config *Route53ZoneConfig, ec2Instance *ec2.Instance, add bool) (*string, error) {
+	resourceRecords, err := r.getResourceRecords(config, ec2Instance)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= maxConflictRetries; attempt++ {
+		if attempt > 1 {
+			r.waitBeforeConflictRetry(attempt)
+		}
+
+		var changes []*route53.Change
+		for _, record := range config.DNSRecords {
+			newChanges, err := r.getSharedChanges(config, record, *ec2Instance.InstanceId, resourceRecords, add)
+			if err != nil {
+				return nil, err
+			}
+			changes = append(changes, newChanges...)
+		}
+
+		if len(changes) == 0 {
+			return nil, nil
+		}
+
+		output, err := r.route53Client.ChangeResourceRecordSets(&route53.ChangeResourceRecordSetsInput{
+			ChangeBatch: &route53.ChangeBatch{
+				Changes: changes,
+			},
+			HostedZoneId: aws.String(config.HostedZoneID),
+		})
+		if err == nil {
+			return getChangeID(output), nil
+		}
+		if !isConflict(err) {
+			return nil, err
+		}
+
+		lastErr = err
+		fmt.Printf("Shared records in %s were modified concurrently, retrying (%d/%d)\n", config.HostedZoneID, attempt, maxConflictRetries)
+	}
+
+	return nil, fmt.Errorf("could not update shared records in %s after %d attempts: %w", config.HostedZoneID, maxConflictRetries, lastErr)
+}
+
+// waitBeforeConflictRetry sleeps for a random delay with an exponentially growing bound, so that
+// invocations updating the same records concurrently do not collide again. An invalid change
+// batch is indistinguishable from a conflict, so the delay also keeps it from being retried in a
+// tight loop.
+func (r *ASGRoute53) waitBeforeConflictRetry(attempt int) {
+	bound := r.conflictRetryDelay << uint(attempt-1)
+	if bound <= 0 {
+		return
+	}
+	if bound > maxRetryDelay {
+		bound = maxRetryDelay
+	}
+
+	time.Sleep(time.Duration(rand.Int63n(int64(bound))))
+}
+
+func (r *ASGRoute53) getSharedChanges(config *Route53ZoneConfig,
+	name string,
+	instanceID string,
+	resourceRecords map[string][]*route53.ResourceRecord,
+	add bool) ([]*route53.Change, error) {
+	current := map[string]*route53.ResourceRecordSet{}
+	for _, recordType := range append([]string{"TXT"}, config.RecordTypes()...) {
+		recordSet, err := r.getRecordSet(config.HostedZoneID, name, recordType, nil)
+		if errors.Is(err, errRecordSetNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		current[recordType] = recordSet
+	}
+
+	var owners []string
+	if current["TXT"] != nil {
+		owners = getOwners(current["TXT"])
+	}
+
+	if !add && !containsValue(owners, instanceID) {
+		fmt.Printf("Skipping %s, TXT record is not owned by %s\n", name, instanceID)
+		return nil, nil
+	}
+
+	desired := map[string][]string{}
+	if add {
+		desired["TXT"] = appendValue(owners, instanceID)
+	} else {
+		desired["TXT"] = removeValue(owners, instanceID)
+	}
+
+	for _, recordType := range config.RecordTypes() {
+		var values []string
+		if current[recordType] != nil {
+			values = getValues(current[recordType].ResourceRecords)
+		}
+		for _, value := range getValues(resourceRecords[recordType]) {
+			if add {
+				values = appendValue(values, value)
+			} else {
+				values = removeValue(values, value)
+			}
+		}
+		if len(desired["TXT"]) == 0 {
+			values = nil
+		}
+		desired[recordType] = values
+	}
+
+	var changes []*route53.Change
+	for _, recordType := range append([]string{"TXT"}, config.RecordTypes()...) {
+		values := desired[recordType]
+		recordSet := current[recordType]
+		if recordSet != nil && equalValues(getValues(recordSet.ResourceRecords), formatValues(recordType, values)) {
+			continue
+		}
+
+		if recordSet != nil {
+			changes = append(changes, &route53.Change{
+				Action:            aws.String("DELETE"),
+				ResourceRecordSet: recordSet,
+			})
+		}
+
+		if len(values) > 0 {
+			changes = append(changes, &route53.Change{
+				Action: aws.String("CREATE"),
+				ResourceRecordSet: &route53.ResourceRecordSet{
+					Name:            aws.String(name),
+					Type:            aws.String(recordType),
+					ResourceRecords: toResourceRecords(formatValues(recordType, values)),
+					TTL:             aws.Int64(config.TTLFor(name)),
+				},
+			})
+		}
+	}
+
+	return changes, nil
+}
+
+// isConflict returns true if a change batch was rejected because the record sets it deletes
+// or creates no longer match the hosted zone
+func isConflict(err error) bool {
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) {
+		return awsErr.Code() == route53.ErrCodeInvalidChangeBatch
+	}
+
+	return false
+}
+
+func formatValues(recordType string, values []string) []string {
+	if recordType != "TXT" {
+		return values
+	}
+
+	var formatted []string
+	for _, value := range values {
+		formatted = append(formatted, fmt.Sprintf("\"%s\"", value))
+	}
+
+	return formatted
+}
+
+func getValues(resourceRecords []*route53.ResourceRecord) []string {
+	var values []string
+	for _, resourceRecord := range resourceRecords {
+		values = append(values, aws.StringValue(resourceRecord.Value))
+	}
+
+	return values
+}
+
+func toResourceRecords(values []string) []*route53.ResourceRecord {
+	var resourceRecords []*route53.ResourceRecord
+	for _, value := range values {
+		resourceRecords = append(resourceRecords, &route53.ResourceRecord{
+			Value: aws.String(value),
+		})
+	}
+
+	return resourceRecords
+}
+
+func containsValue(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func appendValue(values []string, value string) []string {
+	if containsValue(values, value) {
+		return values
+	}
+
+	return append(append([]string{}, values...), value)
+}
+
+func removeValue(values []string, value string) []string {
+	var result []string
+	for _, v := range values {
+		if v != value {
+			result = append(result, v)
+		}
+	}
+
+	return result
+}
+
+func equalValues(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, value := range a {
+		if !containsValue(b, value) {
+			return false
+		}
+	}
+
+	return true
+}
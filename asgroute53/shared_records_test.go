@@ -0,0 +1,161 @@
+package asgroute53
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/route53"
+)
+
+func TestASGRoute53_updateSharedRecordSets(t *testing.T) {
+	config := &Route53ZoneConfig{
+		HostedZoneID:  "ID",
+		DNSRecords:    []string{"pool.example.com"},
+		AddressFamily: AddressFamilyIPv4,
+		SharedRecords: true,
+	}
+	instance := &ec2.Instance{
+		InstanceId:       aws.String("i-1"),
+		PrivateIpAddress: aws.String("10.0.0.1"),
+	}
+	sharedRecordSets := func(owners []string, addresses []string) []*route53.ResourceRecordSet {
+		return []*route53.ResourceRecordSet{
+			{
+				Name:            aws.String("pool.example.com."),
+				Type:            aws.String("A"),
+				ResourceRecords: toResourceRecords(addresses),
+				TTL:             aws.Int64(defaultTTL),
+			},
+			{
+				Name:            aws.String("pool.example.com."),
+				Type:            aws.String("TXT"),
+				ResourceRecords: toResourceRecords(formatValues("TXT", owners)),
+				TTL:             aws.Int64(defaultTTL),
+			},
+		}
+	}
+	tests := []struct {
+		name        string
+		m           *mockedRoute53
+		add         bool
+		wantActions []string
+		wantA       []string
+		wantCalls   int
+		wantErr     bool
+	}{
+		{
+			name: "add-first",
+			m: &mockedRoute53{
+				resourceRecordSets:             []*route53.ResourceRecordSet{},
+				changeResourceRecordSetsOutput: &route53.ChangeResourceRecordSetsOutput{},
+			},
+			add:         true,
+			wantActions: []string{"CREATE TXT", "CREATE A"},
+			wantA:       []string{"10.0.0.1"},
+			wantCalls:   1,
+			wantErr:     false,
+		},
+		{
+			name: "add-to-pool",
+			m: &mockedRoute53{
+				resourceRecordSets:             sharedRecordSets([]string{"i-2"}, []string{"10.0.0.2"}),
+				changeResourceRecordSetsOutput: &route53.ChangeResourceRecordSetsOutput{},
+			},
+			add:         true,
+			wantActions: []string{"DELETE TXT", "CREATE TXT", "DELETE A", "CREATE A"},
+			wantA:       []string{"10.0.0.2", "10.0.0.1"},
+			wantCalls:   1,
+			wantErr:     false,
+		},
+		{
+			name: "add-already-registered",
+			m: &mockedRoute53{
+				resourceRecordSets: sharedRecordSets([]string{"i-1", "i-2"}, []string{"10.0.0.1", "10.0.0.2"}),
+			},
+			add:       true,
+			wantCalls: 0,
+			wantErr:   false,
+		},
+		{
+			name: "remove-from-pool",
+			m: &mockedRoute53{
+				resourceRecordSets:             sharedRecordSets([]string{"i-1", "i-2"}, []string{"10.0.0.1", "10.0.0.2"}),
+				changeResourceRecordSetsOutput: &route53.ChangeResourceRecordSetsOutput{},
+			},
+			add:         false,
+			wantActions: []string{"DELETE TXT", "CREATE TXT", "DELETE A", "CREATE A"},
+			wantA:       []string{"10.0.0.2"},
+			wantCalls:   1,
+			wantErr:     false,
+		},
+		{
+			name: "remove-last",
+			m: &mockedRoute53{
+				resourceRecordSets:             sharedRecordSets([]string{"i-1"}, []string{"10.0.0.1"}),
+				changeResourceRecordSetsOutput: &route53.ChangeResourceRecordSetsOutput{},
+			},
+			add:         false,
+			wantActions: []string{"DELETE TXT", "DELETE A"},
+			wantCalls:   1,
+			wantErr:     false,
+		},
+		{
+			name: "remove-not-owned",
+			m: &mockedRoute53{
+				resourceRecordSets: sharedRecordSets([]string{"i-2"}, []string{"10.0.0.2"}),
+			},
+			add:       false,
+			wantCalls: 0,
+			wantErr:   false,
+		},
+		{
+			name: "conflict",
+			m: &mockedRoute53{
+				resourceRecordSets:           sharedRecordSets([]string{"i-2"}, []string{"10.0.0.2"}),
+				changeResourceRecordSetError: awserr.New(route53.ErrCodeInvalidChangeBatch, "conflict", nil),
+			},
+			add:         true,
+			wantActions: []string{"DELETE TXT", "CREATE TXT", "DELETE A", "CREATE A"},
+			wantA:       []string{"10.0.0.2", "10.0.0.1"},
+			wantCalls:   maxConflictRetries,
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := New(tt.m)
+			r.conflictRetryDelay = time.Millisecond
+			_, err := r.updateSharedRecordSets(config, instance, tt.add)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ASGRoute53.updateSharedRecordSets() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !isConflict(err) {
+				t.Errorf("ASGRoute53.updateSharedRecordSets() error = %v, want the Route 53 error wrapped", err)
+			}
+			if len(tt.m.changeResourceRecordSetsInputs) != tt.wantCalls {
+				t.Fatalf("ASGRoute53.updateSharedRecordSets() calls = %v, want %v", len(tt.m.changeResourceRecordSetsInputs), tt.wantCalls)
+			}
+			if tt.wantCalls == 0 {
+				return
+			}
+
+			var gotActions []string
+			var gotA []string
+			for _, change := range tt.m.changeResourceRecordSetsInputs[0].ChangeBatch.Changes {
+				gotActions = append(gotActions, *change.Action+" "+*change.ResourceRecordSet.Type)
+				if *change.Action == "CREATE" && *change.ResourceRecordSet.Type == "A" {
+					gotA = getValues(change.ResourceRecordSet.ResourceRecords)
+				}
+			}
+			if !equalValues(gotActions, tt.wantActions) || len(gotActions) != len(tt.wantActions) {
+				t.Errorf("ASGRoute53.updateSharedRecordSets() actions = %v, want %v", gotActions, tt.wantActions)
+			}
+			if !equalValues(gotA, tt.wantA) {
+				t.Errorf("ASGRoute53.updateSharedRecordSets() A = %v, want %v", gotA, tt.wantA)
+			}
+		})
+	}
+}
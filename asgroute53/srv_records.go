@@ -0,0 +1,182 @@
+package asgroute53
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/route53"
+)
+
+// SRVConfig holds the SRV record set advertising the records of a zone configuration
+type SRVConfig struct {
+	Service  string
+	Protocol string
+	Port     int64
+	Priority int64
+	Weight   int64
+	// Name is the SRV record name, _service._protocol at the apex of the hosted zone
+	Name string
+}
+
+// loadSRV reads the optional SRV record set whose targets are the records of the configuration.
+// Every instance adds its own targets to the set, so records shared by instances or CNAMEs, which
+// SRV targets must not be, cannot be advertised, and record names must be unique to the instance.
+func (l Route53ZoneConfigLoader) loadSRV(tags *[]*ec2.Tag, keys zoneTagKeys, config *Route53ZoneConfig) error {
+	service := l.findValueFromEC2Tags(tags, keys.srvService)
+	if service == nil {
+		for _, key := range []string{keys.srvProtocol, keys.srvPort, keys.srvPriority, keys.srvWeight} {
+			if l.findValueFromEC2Tags(tags, key) != nil {
+				return fmt.Errorf("%s is required to configure SRV records", keys.srvService)
+			}
+		}
+		return nil
+	}
+
+	srv := &SRVConfig{
+		Service:  strings.TrimPrefix(*service, "_"),
+		Protocol: "tcp",
+	}
+	if srv.Service == "" {
+		return fmt.Errorf("%s should not be empty", keys.srvService)
+	}
+
+	if value := l.findValueFromEC2Tags(tags, keys.srvProtocol); value != nil {
+		srv.Protocol = strings.ToLower(strings.TrimPrefix(*value, "_"))
+	}
+	if srv.Protocol != "tcp" && srv.Protocol != "udp" {
+		return fmt.Errorf("%s should be either tcp or udp: %s", keys.srvProtocol, srv.Protocol)
+	}
+
+	port := l.findValueFromEC2Tags(tags, keys.srvPort)
+	if port == nil {
+		return fmt.Errorf("%s is required to configure SRV records", keys.srvPort)
+	}
+
+	parameters := []struct {
+		key   string
+		value *string
+		dest  *int64
+	}{
+		{keys.srvPort, port, &srv.Port},
+		{keys.srvPriority, l.findValueFromEC2Tags(tags, keys.srvPriority), &srv.Priority},
+		{keys.srvWeight, l.findValueFromEC2Tags(tags, keys.srvWeight), &srv.Weight},
+	}
+	for _, parameter := range parameters {
+		if parameter.value == nil {
+			continue
+		}
+		value, err := strconv.ParseInt(*parameter.value, 10, 64)
+		if err != nil || value < 0 || value > 65535 {
+			return fmt.Errorf("%s should be an integer between 0 and 65535: %s", parameter.key, *parameter.value)
+		}
+		*parameter.dest = value
+	}
+
+	if config.RecordType == RecordTypeCNAME {
+		return fmt.Errorf("%s cannot be used with %s record type", keys.srvService, RecordTypeCNAME)
+	}
+
+	if config.SharedRecords {
+		return fmt.Errorf("%s cannot be combined with %s", keys.srvService, keys.sharedRecords)
+	}
+
+	// An entry written by several instances would be removed by the first of them to terminate
+	for _, record := range config.DNSRecords {
+		if !hasInstancePlaceholder(record) {
+			return fmt.Errorf("%s requires every record of %s to contain {instance-id}, {private-ip-dashed} or {public-ip-dashed}: %s",
+				keys.srvService, keys.dnsRecords, record)
+		}
+	}
+
+	config.SRV = srv
+
+	return nil
+}
+
+// srvValues returns the SRV entries of the instance, one per record of the expanded configuration
+func (c *Route53ZoneConfig) srvValues() []string {
+	var values []string
+	for _, record := range c.DNSRecords {
+		values = append(values, fmt.Sprintf("%d %d %d %s", c.SRV.Priority, c.SRV.Weight, c.SRV.Port, record))
+	}
+
+	return values
+}
+
+// updateSRVRecordSet adds or removes the entries of the instance in the SRV record set, leaving
+// entries of other instances untouched. Like shared records, the set is deleted and recreated in
+// one change batch and retried if another invocation modified it in the meantime. It returns the
+// ID of the applied change, or nil if nothing had to be changed.
+func (r *ASGRoute53) updateSRVRecordSet(config *Route53ZoneConfig, add bool) (*string, error) {
+	var lastErr error
+	for attempt := 1; attempt <= maxConflictRetries; attempt++ {
+		if attempt > 1 {
+			r.waitBeforeConflictRetry(attempt)
+		}
+
+		current, err := r.getRecordSet(config.HostedZoneID, config.SRV.Name, "SRV", nil)
+		if errors.Is(err, errRecordSetNotFound) {
+			current = nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		var values []string
+		if current != nil {
+			values = getValues(current.ResourceRecords)
+		}
+		for _, value := range config.srvValues() {
+			if add {
+				values = appendValue(values, value)
+			} else {
+				values = removeValue(values, value)
+			}
+		}
+
+		if (current != nil && equalValues(getValues(current.ResourceRecords), values)) ||
+			(current == nil && len(values) == 0) {
+			return nil, nil
+		}
+
+		var changes []*route53.Change
+		if current != nil {
+			changes = append(changes, &route53.Change{
+				Action:            aws.String("DELETE"),
+				ResourceRecordSet: current,
+			})
+		}
+		if len(values) > 0 {
+			changes = append(changes, &route53.Change{
+				Action: aws.String("CREATE"),
+				ResourceRecordSet: &route53.ResourceRecordSet{
+					Name:            aws.String(config.SRV.Name),
+					Type:            aws.String("SRV"),
+					ResourceRecords: toResourceRecords(values),
+					TTL:             aws.Int64(config.TTLFor(config.SRV.Name)),
+				},
+			})
+		}
+
+		output, err := r.route53Client.ChangeResourceRecordSets(&route53.ChangeResourceRecordSetsInput{
+			ChangeBatch: &route53.ChangeBatch{
+				Changes: changes,
+			},
+			HostedZoneId: aws.String(config.HostedZoneID),
+		})
+		if err == nil {
+			return getChangeID(output), nil
+		}
+		if !isConflict(err) {
+			return nil, err
+		}
+
+		lastErr = err
+		fmt.Printf("SRV record %s was modified concurrently, retrying (%d/%d)\n", config.SRV.Name, attempt, maxConflictRetries)
+	}
+
+	return nil, fmt.Errorf("could not update SRV record %s after %d attempts: %w", config.SRV.Name, maxConflictRetries, lastErr)
+}
@@ -0,0 +1,401 @@
+package asgroute53
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/route53"
+)
+
+func TestASGRoute53_updateSRVRecordSet(t *testing.T) {
+	config := &Route53ZoneConfig{
+		HostedZoneID: "ID",
+		DNSRecords:   []string{"i-1.nodes.example.com"},
+		SRV: &SRVConfig{
+			Service:  "http",
+			Protocol: "tcp",
+			Port:     8080,
+			Priority: 10,
+			Weight:   5,
+			Name:     "_http._tcp.example.com",
+		},
+	}
+	srvRecordSets := func(values []string) []*route53.ResourceRecordSet {
+		return []*route53.ResourceRecordSet{
+			{
+				Name:            aws.String("_http._tcp.example.com."),
+				Type:            aws.String("SRV"),
+				ResourceRecords: toResourceRecords(values),
+				TTL:             aws.Int64(defaultTTL),
+			},
+		}
+	}
+	tests := []struct {
+		name        string
+		m           *mockedRoute53
+		add         bool
+		wantActions []string
+		wantValues  []string
+		wantCalls   int
+		wantErr     bool
+	}{
+		{
+			name: "add-first",
+			m: &mockedRoute53{
+				resourceRecordSets:             []*route53.ResourceRecordSet{},
+				changeResourceRecordSetsOutput: &route53.ChangeResourceRecordSetsOutput{},
+			},
+			add:         true,
+			wantActions: []string{"CREATE SRV"},
+			wantValues:  []string{"10 5 8080 i-1.nodes.example.com"},
+			wantCalls:   1,
+			wantErr:     false,
+		},
+		{
+			name: "add-to-existing",
+			m: &mockedRoute53{
+				resourceRecordSets:             srvRecordSets([]string{"10 5 8080 i-2.nodes.example.com"}),
+				changeResourceRecordSetsOutput: &route53.ChangeResourceRecordSetsOutput{},
+			},
+			add:         true,
+			wantActions: []string{"DELETE SRV", "CREATE SRV"},
+			wantValues:  []string{"10 5 8080 i-2.nodes.example.com", "10 5 8080 i-1.nodes.example.com"},
+			wantCalls:   1,
+			wantErr:     false,
+		},
+		{
+			name: "remove-keeps-others",
+			m: &mockedRoute53{
+				resourceRecordSets:             srvRecordSets([]string{"10 5 8080 i-1.nodes.example.com", "10 5 8080 i-2.nodes.example.com"}),
+				changeResourceRecordSetsOutput: &route53.ChangeResourceRecordSetsOutput{},
+			},
+			add:         false,
+			wantActions: []string{"DELETE SRV", "CREATE SRV"},
+			wantValues:  []string{"10 5 8080 i-2.nodes.example.com"},
+			wantCalls:   1,
+			wantErr:     false,
+		},
+		{
+			name: "remove-last",
+			m: &mockedRoute53{
+				resourceRecordSets:             srvRecordSets([]string{"10 5 8080 i-1.nodes.example.com"}),
+				changeResourceRecordSetsOutput: &route53.ChangeResourceRecordSetsOutput{},
+			},
+			add:         false,
+			wantActions: []string{"DELETE SRV"},
+			wantCalls:   1,
+			wantErr:     false,
+		},
+		{
+			name: "remove-not-registered",
+			m: &mockedRoute53{
+				resourceRecordSets: srvRecordSets([]string{"10 5 8080 i-2.nodes.example.com"}),
+			},
+			add:       false,
+			wantCalls: 0,
+			wantErr:   false,
+		},
+		{
+			name: "conflict",
+			m: &mockedRoute53{
+				resourceRecordSets:           []*route53.ResourceRecordSet{},
+				changeResourceRecordSetError: awserr.New(route53.ErrCodeInvalidChangeBatch, "conflict", nil),
+			},
+			add:       true,
+			wantCalls: maxConflictRetries,
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := New(tt.m)
+			r.conflictRetryDelay = time.Millisecond
+			_, err := r.updateSRVRecordSet(config, tt.add)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ASGRoute53.updateSRVRecordSet() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !isConflict(err) {
+				t.Errorf("ASGRoute53.updateSRVRecordSet() error = %v, want the Route 53 error wrapped", err)
+			}
+			if got := len(tt.m.changeResourceRecordSetsInputs); got != tt.wantCalls {
+				t.Fatalf("ASGRoute53.updateSRVRecordSet() calls = %v, want %v", got, tt.wantCalls)
+			}
+			if tt.wantCalls == 0 || tt.wantErr {
+				return
+			}
+
+			var actions []string
+			var values []string
+			for _, change := range tt.m.changeResourceRecordSetsInputs[0].ChangeBatch.Changes {
+				actions = append(actions, *change.Action+" "+*change.ResourceRecordSet.Type)
+				if *change.Action == "CREATE" {
+					values = getValues(change.ResourceRecordSet.ResourceRecords)
+				}
+			}
+			if !reflect.DeepEqual(actions, tt.wantActions) {
+				t.Errorf("ASGRoute53.updateSRVRecordSet() actions = %v, want %v", actions, tt.wantActions)
+			}
+			if !reflect.DeepEqual(values, tt.wantValues) {
+				t.Errorf("ASGRoute53.updateSRVRecordSet() values = %v, want %v", values, tt.wantValues)
+			}
+		})
+	}
+}
+
+func Test_loadSRV(t *testing.T) {
+	tests := []struct {
+		name    string
+		tags    *[]*ec2.Tag
+		config  *Route53ZoneConfig
+		want    *SRVConfig
+		wantErr bool
+	}{
+		{
+			name:    "none",
+			tags:    &[]*ec2.Tag{},
+			config:  &Route53ZoneConfig{},
+			want:    nil,
+			wantErr: false,
+		},
+		{
+			name: "defaults",
+			tags: &[]*ec2.Tag{
+				{Key: aws.String(privateSRVServiceKey), Value: aws.String("_ldap")},
+				{Key: aws.String(privateSRVPortKey), Value: aws.String("389")},
+			},
+			config: &Route53ZoneConfig{DNSRecords: []string{"{instance-id}.ldap.example.com"}},
+			want: &SRVConfig{
+				Service:  "ldap",
+				Protocol: "tcp",
+				Port:     389,
+			},
+			wantErr: false,
+		},
+		{
+			name: "all",
+			tags: &[]*ec2.Tag{
+				{Key: aws.String(privateSRVServiceKey), Value: aws.String("sip")},
+				{Key: aws.String(privateSRVProtocolKey), Value: aws.String("UDP")},
+				{Key: aws.String(privateSRVPortKey), Value: aws.String("5060")},
+				{Key: aws.String(privateSRVPriorityKey), Value: aws.String("10")},
+				{Key: aws.String(privateSRVWeightKey), Value: aws.String("20")},
+			},
+			config: &Route53ZoneConfig{DNSRecords: []string{"{private-ip-dashed}.sip.example.com"}},
+			want: &SRVConfig{
+				Service:  "sip",
+				Protocol: "udp",
+				Port:     5060,
+				Priority: 10,
+				Weight:   20,
+			},
+			wantErr: false,
+		},
+		{
+			name: "port-missing",
+			tags: &[]*ec2.Tag{
+				{Key: aws.String(privateSRVServiceKey), Value: aws.String("ldap")},
+			},
+			config:  &Route53ZoneConfig{},
+			wantErr: true,
+		},
+		{
+			name: "service-missing",
+			tags: &[]*ec2.Tag{
+				{Key: aws.String(privateSRVPortKey), Value: aws.String("389")},
+			},
+			config:  &Route53ZoneConfig{},
+			wantErr: true,
+		},
+		{
+			name: "invalid-protocol",
+			tags: &[]*ec2.Tag{
+				{Key: aws.String(privateSRVServiceKey), Value: aws.String("ldap")},
+				{Key: aws.String(privateSRVProtocolKey), Value: aws.String("sctp")},
+				{Key: aws.String(privateSRVPortKey), Value: aws.String("389")},
+			},
+			config:  &Route53ZoneConfig{},
+			wantErr: true,
+		},
+		{
+			name: "invalid-port",
+			tags: &[]*ec2.Tag{
+				{Key: aws.String(privateSRVServiceKey), Value: aws.String("ldap")},
+				{Key: aws.String(privateSRVPortKey), Value: aws.String("65536")},
+			},
+			config:  &Route53ZoneConfig{},
+			wantErr: true,
+		},
+		{
+			name: "shared-records",
+			tags: &[]*ec2.Tag{
+				{Key: aws.String(privateSRVServiceKey), Value: aws.String("ldap")},
+				{Key: aws.String(privateSRVPortKey), Value: aws.String("389")},
+			},
+			config:  &Route53ZoneConfig{SharedRecords: true},
+			wantErr: true,
+		},
+		{
+			name: "shared-name",
+			tags: &[]*ec2.Tag{
+				{Key: aws.String(privateSRVServiceKey), Value: aws.String("http")},
+				{Key: aws.String(privateSRVPortKey), Value: aws.String("80")},
+			},
+			config:  &Route53ZoneConfig{DNSRecords: []string{"{instance-id}.example.com", "pool.example.com"}},
+			wantErr: true,
+		},
+		{
+			name: "not-instance-placeholder",
+			tags: &[]*ec2.Tag{
+				{Key: aws.String(privateSRVServiceKey), Value: aws.String("http")},
+				{Key: aws.String(privateSRVPortKey), Value: aws.String("80")},
+			},
+			config:  &Route53ZoneConfig{DNSRecords: []string{"{az}.example.com"}},
+			wantErr: true,
+		},
+		{
+			name: "cname",
+			tags: &[]*ec2.Tag{
+				{Key: aws.String(privateSRVServiceKey), Value: aws.String("ldap")},
+				{Key: aws.String(privateSRVPortKey), Value: aws.String("389")},
+			},
+			config:  &Route53ZoneConfig{RecordType: RecordTypeCNAME},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewZoneConfigLoader(&mockedRoute53{}).loadSRV(tt.tags, privateZoneTagKeys, tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("loadSRV() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(tt.config.SRV, tt.want) {
+				t.Errorf("loadSRV() = %v, want %v", tt.config.SRV, tt.want)
+			}
+		})
+	}
+}
+
+func TestChangeBatch_Submit_srv(t *testing.T) {
+	m := &mockedRoute53{
+		resourceRecordSets:             []*route53.ResourceRecordSet{},
+		changeResourceRecordSetsOutput: &route53.ChangeResourceRecordSetsOutput{},
+	}
+	config := &Route53ZoneConfig{
+		HostedZoneID:  "ID",
+		DNSRecords:    []string{"{instance-id}.nodes.example.com"},
+		SetIdentifier: aws.String(instanceIDTemplate),
+		RoutingPolicy: RoutingPolicyMultivalue,
+		SRV: &SRVConfig{
+			Service:  "http",
+			Protocol: "tcp",
+			Port:     80,
+			Name:     "_http._tcp.example.com",
+		},
+	}
+	batch := New(m).NewChangeBatch()
+	if err := batch.UpsertRecordSets("k", config, &ec2.Instance{
+		InstanceId:       aws.String("i-1"),
+		PrivateIpAddress: aws.String("10.0.0.1"),
+	}); err != nil {
+		t.Fatalf("ChangeBatch.UpsertRecordSets() error = %v", err)
+	}
+	if len(m.changeResourceRecordSetsInputs) != 0 {
+		t.Fatalf("ChangeBatch.UpsertRecordSets() applied changes before Submit")
+	}
+
+	if _, errs := batch.Submit(); errs["k"] != nil {
+		t.Fatalf("ChangeBatch.Submit() error = %v", errs["k"])
+	}
+	if got := len(m.changeResourceRecordSetsInputs); got != 2 {
+		t.Fatalf("ChangeBatch.Submit() calls = %v, want 2", got)
+	}
+	srvChange := m.changeResourceRecordSetsInputs[1].ChangeBatch.Changes[0]
+	if got := getValues(srvChange.ResourceRecordSet.ResourceRecords); !reflect.DeepEqual(got, []string{"0 0 80 i-1.nodes.example.com"}) {
+		t.Errorf("ChangeBatch.Submit() SRV values = %v", got)
+	}
+}
+
+func TestChangeBatch_DeleteRecordSets_srv(t *testing.T) {
+	recordSets := func(owner string) []*route53.ResourceRecordSet {
+		return []*route53.ResourceRecordSet{
+			{
+				Name:            aws.String("_http._tcp.example.com."),
+				Type:            aws.String("SRV"),
+				ResourceRecords: toResourceRecords([]string{"0 0 80 10-0-0-1.nodes.example.com"}),
+				TTL:             aws.Int64(defaultTTL),
+			},
+			{
+				Name:            aws.String("10-0-0-1.nodes.example.com."),
+				Type:            aws.String("A"),
+				ResourceRecords: toResourceRecords([]string{"10.0.0.1"}),
+				TTL:             aws.Int64(defaultTTL),
+			},
+			{
+				Name:            aws.String("10-0-0-1.nodes.example.com."),
+				Type:            aws.String("TXT"),
+				ResourceRecords: toResourceRecords(formatValues("TXT", []string{owner})),
+				TTL:             aws.Int64(defaultTTL),
+			},
+		}
+	}
+	tests := []struct {
+		name        string
+		owner       string
+		wantActions []string
+	}{
+		{
+			name:        "owned",
+			owner:       "i-1",
+			wantActions: []string{"DELETE SRV", "DELETE TXT", "DELETE A"},
+		},
+		{
+			name:        "claimed-by-replacement",
+			owner:       "i-2",
+			wantActions: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &mockedRoute53{
+				resourceRecordSets:             recordSets(tt.owner),
+				changeResourceRecordSetsOutput: &route53.ChangeResourceRecordSetsOutput{},
+			}
+			config := &Route53ZoneConfig{
+				HostedZoneID:  "ID",
+				DNSRecords:    []string{"{private-ip-dashed}.nodes.example.com"},
+				RoutingPolicy: RoutingPolicySimple,
+				SRV: &SRVConfig{
+					Service:  "http",
+					Protocol: "tcp",
+					Port:     80,
+					Name:     "_http._tcp.example.com",
+				},
+			}
+			batch := New(m).NewChangeBatch()
+			if err := batch.DeleteRecordSets("k", config, &ec2.Instance{
+				InstanceId:       aws.String("i-1"),
+				PrivateIpAddress: aws.String("10.0.0.1"),
+			}); err != nil {
+				t.Fatalf("ChangeBatch.DeleteRecordSets() error = %v", err)
+			}
+			if _, errs := batch.Submit(); errs["k"] != nil {
+				t.Fatalf("ChangeBatch.Submit() error = %v", errs["k"])
+			}
+
+			var actions []string
+			for _, input := range m.changeResourceRecordSetsInputs {
+				for _, change := range input.ChangeBatch.Changes {
+					actions = append(actions, *change.Action+" "+*change.ResourceRecordSet.Type)
+				}
+			}
+			if !reflect.DeepEqual(actions, tt.wantActions) {
+				t.Errorf("ChangeBatch.DeleteRecordSets() actions = %v, want %v", actions, tt.wantActions)
+			}
+		})
+	}
+}
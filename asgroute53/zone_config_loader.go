@@ -2,6 +2,8 @@ package asgroute53
 
 import (
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -21,7 +23,88 @@ type (
 		DNSRecords    []string
 		SetIdentifier *string
 		IsPublic      bool
+		AddressFamily string
+		SharedRecords bool
+		RoutingPolicy string
+		Weight        *int64
+		Region        *string
+		GeoLocation   *route53.GeoLocation
+		Failover      *string
+		HealthCheck   *HealthCheckConfig
+		TTL           *int64
+		RecordTTLs    map[string]int64
+		// AddressSource selects which addresses of the network interfaces are put in the records
+		AddressSource         string
+		NetworkInterfaceIndex *int64
+		NetworkInterfaceTag   *ec2.Tag
+		// NetworkInterfaceID is resolved from NetworkInterfaceTag before the records are updated
+		NetworkInterfaceID *string
+		RecordType         string
+		SRV                *SRVConfig
+		// ReverseHostedZoneID is the reverse zone receiving PTR records for the record addresses
+		ReverseHostedZoneID *string
+		// ReverseZoneName is the name of the reverse zone, resolved along with its ID
+		ReverseZoneName string
 	}
+	// zoneTagKeys holds tag keys used for either private or public zone configuration
+	zoneTagKeys struct {
+		hostedZoneID           string
+		dnsRecords             string
+		setIdentifier          string
+		addressFamily          string
+		sharedRecords          string
+		routingPolicy          string
+		weight                 string
+		region                 string
+		geoLocationContinent   string
+		geoLocationCountry     string
+		geoLocationSubdivision string
+		failover               string
+		healthCheckType        string
+		healthCheckPort        string
+		healthCheckPath        string
+		ttl                    string
+		addressSource          string
+		networkInterfaceIndex  string
+		networkInterfaceTag    string
+		recordType             string
+		srvService             string
+		srvProtocol            string
+		srvPort                string
+		srvPriority            string
+		srvWeight              string
+		// reverseHostedZoneID is only set for private zones
+		reverseHostedZoneID string
+	}
+)
+
+// Address families selectable with the address-family tag
+const (
+	AddressFamilyIPv4 = "ipv4"
+	AddressFamilyIPv6 = "ipv6"
+	AddressFamilyDual = "dual"
+)
+
+// Record types selectable with the record-type tag
+const (
+	// RecordTypeAddress points the records at the addresses of the instance
+	RecordTypeAddress = "address"
+	// RecordTypeCNAME points the records at the EC2 DNS name of the instance
+	RecordTypeCNAME = "cname"
+)
+
+// cnameOwnerPrefix is prepended to CNAME record names to get the name of their TXT ownership
+// record, because Route 53 does not allow other records next to a CNAME
+const cnameOwnerPrefix = "_asg-route53-owner."
+
+// Routing policies selectable with the routing-policy tag
+const (
+	RoutingPolicySimple      = "simple"
+	RoutingPolicyMultivalue  = "multivalue"
+	RoutingPolicyWeighted    = "weighted"
+	RoutingPolicyLatency     = "latency"
+	RoutingPolicyGeolocation = "geolocation"
+	RoutingPolicyFailover    = "failover"
 )
 
 const privateHostedZoneIDKey = "asg-route53-lambda:private-hosted-zone-id"
@@ -30,6 +113,149 @@ const privateSetIdentifierKey = "asg-route53-lambda:private-set-identifier"
 const publicHostedZoneIDKey = "asg-route53-lambda:public-hosted-zone-id"
 const publicDNSRecordsKey = "asg-route53-lambda:public-dns-records"
 const publicSetIdentifierKey = "asg-route53-lambda:public-set-identifier"
+const privateAddressFamilyKey = "asg-route53-lambda:private-address-family"
+const publicAddressFamilyKey = "asg-route53-lambda:public-address-family"
+const privateSharedRecordsKey = "asg-route53-lambda:private-shared-records"
+const publicSharedRecordsKey = "asg-route53-lambda:public-shared-records"
+const privateRoutingPolicyKey = "asg-route53-lambda:private-routing-policy"
+const publicRoutingPolicyKey = "asg-route53-lambda:public-routing-policy"
+const privateWeightKey = "asg-route53-lambda:private-weight"
+const publicWeightKey = "asg-route53-lambda:public-weight"
+const privateRegionKey = "asg-route53-lambda:private-region"
+const publicRegionKey = "asg-route53-lambda:public-region"
+const privateGeoLocationContinentKey = "asg-route53-lambda:private-geolocation-continent"
+const publicGeoLocationContinentKey = "asg-route53-lambda:public-geolocation-continent"
+const privateGeoLocationCountryKey = "asg-route53-lambda:private-geolocation-country"
+const publicGeoLocationCountryKey = "asg-route53-lambda:public-geolocation-country"
+const privateGeoLocationSubdivisionKey = "asg-route53-lambda:private-geolocation-subdivision"
+const publicGeoLocationSubdivisionKey = "asg-route53-lambda:public-geolocation-subdivision"
+const privateFailoverKey = "asg-route53-lambda:private-failover"
+const publicFailoverKey = "asg-route53-lambda:public-failover"
+const privateHealthCheckTypeKey = "asg-route53-lambda:private-health-check-type"
+const publicHealthCheckTypeKey = "asg-route53-lambda:public-health-check-type"
+const privateHealthCheckPortKey = "asg-route53-lambda:private-health-check-port"
+const publicHealthCheckPortKey = "asg-route53-lambda:public-health-check-port"
+const privateHealthCheckPathKey = "asg-route53-lambda:private-health-check-path"
+const publicHealthCheckPathKey = "asg-route53-lambda:public-health-check-path"
+const privateTTLKey = "asg-route53-lambda:private-ttl"
+const publicTTLKey = "asg-route53-lambda:public-ttl"
+const privateAddressSourceKey = "asg-route53-lambda:private-address-source"
+const publicAddressSourceKey = "asg-route53-lambda:public-address-source"
+const privateNetworkInterfaceIndexKey = "asg-route53-lambda:private-network-interface-index"
+const publicNetworkInterfaceIndexKey = "asg-route53-lambda:public-network-interface-index"
+const privateNetworkInterfaceTagKey = "asg-route53-lambda:private-network-interface-tag"
+const publicNetworkInterfaceTagKey = "asg-route53-lambda:public-network-interface-tag"
+const privateRecordTypeKey = "asg-route53-lambda:private-record-type"
+const publicRecordTypeKey = "asg-route53-lambda:public-record-type"
+const privateReverseHostedZoneIDKey = "asg-route53-lambda:private-reverse-hosted-zone-id"
+const privateSRVServiceKey = "asg-route53-lambda:private-srv-service"
+const publicSRVServiceKey = "asg-route53-lambda:public-srv-service"
+const privateSRVProtocolKey = "asg-route53-lambda:private-srv-protocol"
+const publicSRVProtocolKey = "asg-route53-lambda:public-srv-protocol"
+const privateSRVPortKey = "asg-route53-lambda:private-srv-port"
+const publicSRVPortKey = "asg-route53-lambda:public-srv-port"
+const privateSRVPriorityKey = "asg-route53-lambda:private-srv-priority"
+const publicSRVPriorityKey = "asg-route53-lambda:public-srv-priority"
+const privateSRVWeightKey = "asg-route53-lambda:private-srv-weight"
+const publicSRVWeightKey = "asg-route53-lambda:public-srv-weight"
+
+// maxRoute53TTL is the largest TTL accepted by Route 53
+const maxRoute53TTL = 2147483647
+
+var privateZoneTagKeys = zoneTagKeys{
+	hostedZoneID:           privateHostedZoneIDKey,
+	dnsRecords:             privateDNSRecordsKey,
+	setIdentifier:          privateSetIdentifierKey,
+	addressFamily:          privateAddressFamilyKey,
+	sharedRecords:          privateSharedRecordsKey,
+	routingPolicy:          privateRoutingPolicyKey,
+	weight:                 privateWeightKey,
+	region:                 privateRegionKey,
+	geoLocationContinent:   privateGeoLocationContinentKey,
+	geoLocationCountry:     privateGeoLocationCountryKey,
+	geoLocationSubdivision: privateGeoLocationSubdivisionKey,
+	failover:               privateFailoverKey,
+	healthCheckType:        privateHealthCheckTypeKey,
+	healthCheckPort:        privateHealthCheckPortKey,
+	healthCheckPath:        privateHealthCheckPathKey,
+	ttl:                    privateTTLKey,
+	addressSource:          privateAddressSourceKey,
+	networkInterfaceIndex:  privateNetworkInterfaceIndexKey,
+	networkInterfaceTag:    privateNetworkInterfaceTagKey,
+	recordType:             privateRecordTypeKey,
+	srvService:             privateSRVServiceKey,
+	srvProtocol:            privateSRVProtocolKey,
+	srvPort:                privateSRVPortKey,
+	srvPriority:            privateSRVPriorityKey,
+	srvWeight:              privateSRVWeightKey,
+	reverseHostedZoneID:    privateReverseHostedZoneIDKey,
+}
+
+var publicZoneTagKeys = zoneTagKeys{
+	hostedZoneID:           publicHostedZoneIDKey,
+	dnsRecords:             publicDNSRecordsKey,
+	setIdentifier:          publicSetIdentifierKey,
+	addressFamily:          publicAddressFamilyKey,
+	sharedRecords:          publicSharedRecordsKey,
+	routingPolicy:          publicRoutingPolicyKey,
+	weight:                 publicWeightKey,
+	region:                 publicRegionKey,
+	geoLocationContinent:   publicGeoLocationContinentKey,
+	geoLocationCountry:     publicGeoLocationCountryKey,
+	geoLocationSubdivision: publicGeoLocationSubdivisionKey,
+	failover:               publicFailoverKey,
+	healthCheckType:        publicHealthCheckTypeKey,
+	healthCheckPort:        publicHealthCheckPortKey,
+	healthCheckPath:        publicHealthCheckPathKey,
+	ttl:                    publicTTLKey,
+	addressSource:          publicAddressSourceKey,
+	networkInterfaceIndex:  publicNetworkInterfaceIndexKey,
+	networkInterfaceTag:    publicNetworkInterfaceTagKey,
+	recordType:             publicRecordTypeKey,
+	srvService:             publicSRVServiceKey,
+	srvProtocol:            publicSRVProtocolKey,
+	srvPort:                publicSRVPortKey,
+	srvPriority:            publicSRVPriorityKey,
+	srvWeight:              publicSRVWeightKey,
+}
+
+// withIndex returns the keys of the indexed tag family, e.g. private-hosted-zone-id.1
+func (k zoneTagKeys) withIndex(index int) zoneTagKeys {
+	for _, key := range []*string{
+		&k.hostedZoneID,
+		&k.dnsRecords,
+		&k.setIdentifier,
+		&k.addressFamily,
+		&k.sharedRecords,
+		&k.routingPolicy,
+		&k.weight,
+		&k.region,
+		&k.geoLocationContinent,
+		&k.geoLocationCountry,
+		&k.geoLocationSubdivision,
+		&k.failover,
+		&k.healthCheckType,
+		&k.healthCheckPort,
+		&k.healthCheckPath,
+		&k.ttl,
+		&k.addressSource,
+		&k.networkInterfaceIndex,
+		&k.networkInterfaceTag,
+		&k.recordType,
+		&k.srvService,
+		&k.srvProtocol,
+		&k.srvPort,
+		&k.srvPriority,
+		&k.srvWeight,
+		&k.reverseHostedZoneID,
+	} {
+		if *key != "" {
+			*key = fmt.Sprintf("%s.%d", *key, index)
+		}
+	}
+
+	return k
+}
 
 // NewZoneConfigLoader creates new instance of Route53ZoneConfigLoader
 func NewZoneConfigLoader(route53Client route53iface.Route53API) *Route53ZoneConfigLoader {
@@ -48,52 +274,631 @@ func (l Route53ZoneConfigLoader) findValueFromEC2Tags(tags *[]*ec2.Tag, key stri
 	return nil
 }
 
-// Load loads record set config from EC2 tags
-func (l Route53ZoneConfigLoader) Load(tags *[]*ec2.Tag, isPublic bool) (*Route53ZoneConfig, error) {
-	zoneIDKey := privateHostedZoneIDKey
-	recordsKey := privateDNSRecordsKey
-	setIdentifierKey := privateSetIdentifierKey
+// Load loads the record set configs of the unindexed tag family from EC2 tags. Without a hosted
+// zone ID tag, records are grouped into one config per hosted zone inferred from their names, in
+// which private zones must be associated with vpcID if it is given.
+func (l Route53ZoneConfigLoader) Load(tags *[]*ec2.Tag, isPublic bool, vpcID *string) ([]*Route53ZoneConfig, error) {
+	keys := privateZoneTagKeys
+	if isPublic {
+		keys = publicZoneTagKeys
+	}
+
+	return l.load(tags, keys, isPublic, vpcID)
+}
 
+// LoadAll loads the record set configs of the unindexed tag family and of every indexed family,
+// e.g. private-hosted-zone-id.1 and private-dns-records.1, in index order
+func (l Route53ZoneConfigLoader) LoadAll(tags *[]*ec2.Tag, isPublic bool, vpcID *string) ([]*Route53ZoneConfig, error) {
+	keys := privateZoneTagKeys
 	if isPublic {
-		zoneIDKey = publicHostedZoneIDKey
-		recordsKey = publicDNSRecordsKey
-		setIdentifierKey = publicSetIdentifierKey
+		keys = publicZoneTagKeys
 	}
 
-	zoneID := l.findValueFromEC2Tags(tags, zoneIDKey)
-	inDNSRecords := l.findValueFromEC2Tags(tags, recordsKey)
-	setIdentifier := l.findValueFromEC2Tags(tags, setIdentifierKey)
+	configs, err := l.load(tags, keys, isPublic, vpcID)
+	if err != nil {
+		return nil, err
+	}
 
-	if (zoneID != nil && inDNSRecords == nil) ||
-		(zoneID == nil && inDNSRecords != nil) {
-		return nil, fmt.Errorf("both %s and %s should be specified", zoneIDKey, recordsKey)
+	indexes, err := l.findIndexes(tags, keys)
+	if err != nil {
+		return nil, err
 	}
 
-	if zoneID != nil && inDNSRecords != nil {
-		_, err := l.route53Client.GetHostedZone(&route53.GetHostedZoneInput{
-			Id: zoneID,
-		})
+	for _, index := range indexes {
+		indexed, err := l.load(tags, keys.withIndex(index), isPublic, vpcID)
+		if err != nil {
+			return nil, err
+		}
+		configs = append(configs, indexed...)
+	}
+
+	return configs, nil
+}
+
+// findIndexes returns the indexes of the tag families present in the tags in ascending order
+func (l Route53ZoneConfigLoader) findIndexes(tags *[]*ec2.Tag, keys zoneTagKeys) ([]int, error) {
+	var indexes []int
+	for _, tag := range *tags {
+		for _, key := range []string{keys.hostedZoneID, keys.dnsRecords} {
+			if !strings.HasPrefix(*tag.Key, key+".") {
+				continue
+			}
+
+			value := strings.TrimPrefix(*tag.Key, key+".")
+			index, err := strconv.Atoi(value)
+			if err != nil || index < 1 {
+				return nil, fmt.Errorf("%s should be followed by a positive index: %s", key, *tag.Key)
+			}
+			if !containsIndex(indexes, index) {
+				indexes = append(indexes, index)
+			}
+		}
+	}
+
+	sort.Ints(indexes)
+	return indexes, nil
+}
+
+func containsIndex(indexes []int, index int) bool {
+	for _, i := range indexes {
+		if i == index {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (l Route53ZoneConfigLoader) load(tags *[]*ec2.Tag, keys zoneTagKeys, isPublic bool, vpcID *string) ([]*Route53ZoneConfig, error) {
+	config, err := l.parse(tags, keys, isPublic)
+	if err != nil || config == nil {
+		return nil, err
+	}
+
+	return l.resolve(config, vpcID)
+}
+
+// parse reads a zone config from the tags without calling Route 53. It returns nil if the tags
+// configure no records.
+func (l Route53ZoneConfigLoader) parse(tags *[]*ec2.Tag, keys zoneTagKeys, isPublic bool) (*Route53ZoneConfig, error) {
+	zoneID := l.findValueFromEC2Tags(tags, keys.hostedZoneID)
+	inDNSRecords := l.findValueFromEC2Tags(tags, keys.dnsRecords)
+
+	if zoneID != nil && inDNSRecords == nil {
+		return nil, fmt.Errorf("both %s and %s should be specified", keys.hostedZoneID, keys.dnsRecords)
+	}
+
+	if inDNSRecords == nil {
+		return nil, nil
+	}
+
+	config := &Route53ZoneConfig{
+		HostedZoneID:  aws.StringValue(zoneID),
+		DNSRecords:    strings.Split(*inDNSRecords, ","),
+		SetIdentifier: l.findValueFromEC2Tags(tags, keys.setIdentifier),
+		IsPublic:      isPublic,
+		AddressFamily: AddressFamilyIPv4,
+		AddressSource: AddressSourcePrimary,
+		RecordType:    RecordTypeAddress,
+	}
+
+	if err := l.loadRecordOptions(tags, keys, config); err != nil {
+		return nil, err
+	}
+
+	if err := l.loadAddressSelection(tags, keys, config); err != nil {
+		return nil, err
+	}
+
+	if err := l.loadRecordType(tags, keys, config); err != nil {
+		return nil, err
+	}
+
+	if err := l.loadSRV(tags, keys, config); err != nil {
+		return nil, err
+	}
+
+	if err := l.loadReverseZone(tags, keys, config); err != nil {
+		return nil, err
+	}
 
+	if err := l.loadRoutingPolicy(tags, keys, config); err != nil {
+		return nil, err
+	}
+
+	if err := l.loadHealthCheck(tags, keys, config); err != nil {
+		return nil, err
+	}
+
+	if err := l.loadTTLs(tags, keys, config); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// resolve checks the hosted zones of a parsed config, inferring them from the record names if
+// no hosted zone ID is configured
+func (l Route53ZoneConfigLoader) resolve(config *Route53ZoneConfig, vpcID *string) ([]*Route53ZoneConfig, error) {
+	if config.ReverseHostedZoneID != nil {
+		reverseZone, err := l.route53Client.GetHostedZone(&route53.GetHostedZoneInput{
+			Id: config.ReverseHostedZoneID,
+		})
 		if err != nil {
 			return nil, err
 		}
+		if err := config.setReverseZoneName(aws.StringValue(reverseZone.HostedZone.Name)); err != nil {
+			return nil, err
+		}
+	}
+
+	if config.HostedZoneID == "" {
+		return l.inferHostedZones(config, vpcID)
+	}
+
+	hostedZone, err := l.route53Client.GetHostedZone(&route53.GetHostedZoneInput{
+		Id: aws.String(config.HostedZoneID),
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	config.setZoneName(hostedZone.HostedZone.Name)
+
+	return []*Route53ZoneConfig{config}, nil
+}
+
+func (l Route53ZoneConfigLoader) loadRecordOptions(tags *[]*ec2.Tag, keys zoneTagKeys, config *Route53ZoneConfig) error {
+	if value := l.findValueFromEC2Tags(tags, keys.addressFamily); value != nil {
+		config.AddressFamily = strings.ToLower(*value)
+	}
+
+	switch config.AddressFamily {
+	case AddressFamilyIPv4, AddressFamilyIPv6, AddressFamilyDual:
+	default:
+		return fmt.Errorf("unsupported value for %s: %s", keys.addressFamily, config.AddressFamily)
+	}
+
+	if value := l.findValueFromEC2Tags(tags, keys.sharedRecords); value != nil {
+		sharedRecords, err := strconv.ParseBool(*value)
+		if err != nil {
+			return fmt.Errorf("invalid value for %s: %s", keys.sharedRecords, *value)
+		}
+		config.SharedRecords = sharedRecords
+	}
+
+	if config.SharedRecords && config.SetIdentifier != nil {
+		return fmt.Errorf("%s cannot be combined with %s", keys.sharedRecords, keys.setIdentifier)
+	}
+
+	for _, record := range config.DNSRecords {
+		if err := validateTemplate(record); err != nil {
+			return err
+		}
+	}
 
-		return &Route53ZoneConfig{
-			HostedZoneID:  *zoneID,
-			DNSRecords:    strings.Split(*inDNSRecords, ","),
-			SetIdentifier: setIdentifier,
-			IsPublic:      isPublic,
-		}, nil
+	if config.SetIdentifier != nil {
+		if err := validateTemplate(*config.SetIdentifier); err != nil {
+			return err
+		}
 	}
 
-	return nil, nil
+	return nil
+}
+
+// loadAddressSelection reads which addresses of which network interface are put in the records.
+// Interfaces are selected either by device index or by a Key=Value tag of the interface.
+func (l Route53ZoneConfigLoader) loadAddressSelection(tags *[]*ec2.Tag, keys zoneTagKeys, config *Route53ZoneConfig) error {
+	if value := l.findValueFromEC2Tags(tags, keys.addressSource); value != nil {
+		config.AddressSource = strings.ToLower(*value)
+	}
+
+	switch config.AddressSource {
+	case AddressSourcePrimary, AddressSourceSecondary, AddressSourceElasticIP, AddressSourceAllPrivate:
+	default:
+		return fmt.Errorf("unsupported value for %s: %s", keys.addressSource, config.AddressSource)
+	}
+
+	index := l.findValueFromEC2Tags(tags, keys.networkInterfaceIndex)
+	tag := l.findValueFromEC2Tags(tags, keys.networkInterfaceTag)
+	if index != nil && tag != nil {
+		return fmt.Errorf("%s cannot be combined with %s", keys.networkInterfaceIndex, keys.networkInterfaceTag)
+	}
+
+	if index != nil {
+		value, err := strconv.ParseInt(*index, 10, 64)
+		if err != nil || value < 0 {
+			return fmt.Errorf("%s should be a device index: %s", keys.networkInterfaceIndex, *index)
+		}
+		config.NetworkInterfaceIndex = aws.Int64(value)
+	}
+
+	if tag != nil {
+		parts := strings.SplitN(*tag, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return fmt.Errorf("%s should be in the form Key=Value: %s", keys.networkInterfaceTag, *tag)
+		}
+		config.NetworkInterfaceTag = &ec2.Tag{
+			Key:   aws.String(parts[0]),
+			Value: aws.String(parts[1]),
+		}
+	}
+
+	return nil
+}
+
+// loadRecordType reads whether the records point at addresses or are CNAMEs to the EC2 DNS name.
+// A CNAME is the only record allowed at its name, so it cannot be combined with address options
+// or shared records.
+func (l Route53ZoneConfigLoader) loadRecordType(tags *[]*ec2.Tag, keys zoneTagKeys, config *Route53ZoneConfig) error {
+	if value := l.findValueFromEC2Tags(tags, keys.recordType); value != nil {
+		config.RecordType = strings.ToLower(*value)
+	}
+
+	switch config.RecordType {
+	case RecordTypeAddress:
+		return nil
+	case RecordTypeCNAME:
+	default:
+		return fmt.Errorf("unsupported value for %s: %s", keys.recordType, config.RecordType)
+	}
+
+	for _, key := range []string{keys.addressFamily, keys.addressSource, keys.networkInterfaceIndex, keys.networkInterfaceTag} {
+		if l.findValueFromEC2Tags(tags, key) != nil {
+			return fmt.Errorf("%s cannot be used with %s record type", key, RecordTypeCNAME)
+		}
+	}
+
+	if config.SharedRecords {
+		return fmt.Errorf("%s cannot be used with %s record type", keys.sharedRecords, RecordTypeCNAME)
+	}
+
+	return nil
+}
+
+// loadRoutingPolicy reads the routing policy and its parameters and validates the combination.
+// Without a routing-policy tag, records with a set identifier or templated names default to
+// multivalue and everything else to simple routing.
+func (l Route53ZoneConfigLoader) loadRoutingPolicy(tags *[]*ec2.Tag, keys zoneTagKeys, config *Route53ZoneConfig) error {
+	templated := false
+	for _, record := range config.DNSRecords {
+		templated = templated || hasPlaceholders(record)
+	}
+
+	config.RoutingPolicy = RoutingPolicySimple
+	if config.SetIdentifier != nil || (templated && !config.SharedRecords && config.RecordType != RecordTypeCNAME) {
+		config.RoutingPolicy = RoutingPolicyMultivalue
+	}
+	if value := l.findValueFromEC2Tags(tags, keys.routingPolicy); value != nil {
+		config.RoutingPolicy = strings.ToLower(*value)
+	}
+
+	if config.RecordType == RecordTypeCNAME && config.RoutingPolicy == RoutingPolicyMultivalue {
+		return fmt.Errorf("%s record type cannot be used with %s routing policy", RecordTypeCNAME, RoutingPolicyMultivalue)
+	}
+
+	weight := l.findValueFromEC2Tags(tags, keys.weight)
+	region := l.findValueFromEC2Tags(tags, keys.region)
+	continent := l.findValueFromEC2Tags(tags, keys.geoLocationContinent)
+	country := l.findValueFromEC2Tags(tags, keys.geoLocationCountry)
+	subdivision := l.findValueFromEC2Tags(tags, keys.geoLocationSubdivision)
+	failover := l.findValueFromEC2Tags(tags, keys.failover)
+
+	parameters := []struct {
+		key    string
+		value  *string
+		policy string
+	}{
+		{keys.weight, weight, RoutingPolicyWeighted},
+		{keys.region, region, RoutingPolicyLatency},
+		{keys.geoLocationContinent, continent, RoutingPolicyGeolocation},
+		{keys.geoLocationCountry, country, RoutingPolicyGeolocation},
+		{keys.geoLocationSubdivision, subdivision, RoutingPolicyGeolocation},
+		{keys.failover, failover, RoutingPolicyFailover},
+	}
+	for _, parameter := range parameters {
+		if parameter.value != nil && parameter.policy != config.RoutingPolicy {
+			return fmt.Errorf("%s can only be used with %s routing policy", parameter.key, parameter.policy)
+		}
+	}
+
+	switch config.RoutingPolicy {
+	case RoutingPolicySimple:
+		if config.SetIdentifier != nil {
+			return fmt.Errorf("%s cannot be used with %s routing policy", keys.setIdentifier, RoutingPolicySimple)
+		}
+		return nil
+	case RoutingPolicyMultivalue:
+	case RoutingPolicyWeighted:
+		if weight == nil {
+			return fmt.Errorf("%s is required for %s routing policy", keys.weight, RoutingPolicyWeighted)
+		}
+		value, err := strconv.ParseInt(*weight, 10, 64)
+		if err != nil || value < 0 || value > 255 {
+			return fmt.Errorf("%s should be an integer between 0 and 255: %s", keys.weight, *weight)
+		}
+		config.Weight = aws.Int64(value)
+	case RoutingPolicyLatency:
+		if region == nil || *region == "" {
+			return fmt.Errorf("%s is required for %s routing policy", keys.region, RoutingPolicyLatency)
+		}
+		config.Region = region
+	case RoutingPolicyGeolocation:
+		if (continent == nil) == (country == nil) {
+			return fmt.Errorf("either %s or %s should be specified", keys.geoLocationContinent, keys.geoLocationCountry)
+		}
+		if subdivision != nil && aws.StringValue(country) != "US" {
+			return fmt.Errorf("%s can only be used with country US", keys.geoLocationSubdivision)
+		}
+		config.GeoLocation = &route53.GeoLocation{
+			ContinentCode:   continent,
+			CountryCode:     country,
+			SubdivisionCode: subdivision,
+		}
+	case RoutingPolicyFailover:
+		if failover == nil {
+			return fmt.Errorf("%s is required for %s routing policy", keys.failover, RoutingPolicyFailover)
+		}
+		value := strings.ToUpper(*failover)
+		if value != route53.ResourceRecordSetFailoverPrimary && value != route53.ResourceRecordSetFailoverSecondary {
+			return fmt.Errorf("%s should be either PRIMARY or SECONDARY: %s", keys.failover, *failover)
+		}
+		config.Failover = aws.String(value)
+	default:
+		return fmt.Errorf("unsupported value for %s: %s", keys.routingPolicy, config.RoutingPolicy)
+	}
+
+	if config.SharedRecords {
+		return fmt.Errorf("%s can only be used with %s routing policy", keys.sharedRecords, RoutingPolicySimple)
+	}
+
+	if config.SetIdentifier == nil {
+		config.SetIdentifier = aws.String(instanceIDTemplate)
+	}
+
+	return nil
+}
+
+// loadHealthCheck reads the optional per-instance health check configuration. The health check
+// watches one address, so address options putting several addresses in the records are rejected.
+func (l Route53ZoneConfigLoader) loadHealthCheck(tags *[]*ec2.Tag, keys zoneTagKeys, config *Route53ZoneConfig) error {
+	healthCheckType := l.findValueFromEC2Tags(tags, keys.healthCheckType)
+	port := l.findValueFromEC2Tags(tags, keys.healthCheckPort)
+	path := l.findValueFromEC2Tags(tags, keys.healthCheckPath)
+
+	if healthCheckType == nil {
+		if port != nil || path != nil {
+			return fmt.Errorf("%s is required to configure a health check", keys.healthCheckType)
+		}
+		return nil
+	}
+
+	healthCheck := &HealthCheckConfig{
+		Type: strings.ToUpper(*healthCheckType),
+	}
+
+	switch healthCheck.Type {
+	case route53.HealthCheckTypeHttp:
+		healthCheck.Port = 80
+	case route53.HealthCheckTypeHttps:
+		healthCheck.Port = 443
+	case route53.HealthCheckTypeTcp:
+		if port == nil {
+			return fmt.Errorf("%s is required for TCP health checks", keys.healthCheckPort)
+		}
+		if path != nil {
+			return fmt.Errorf("%s cannot be used with TCP health checks", keys.healthCheckPath)
+		}
+	default:
+		return fmt.Errorf("unsupported value for %s: %s", keys.healthCheckType, *healthCheckType)
+	}
+
+	if port != nil {
+		value, err := strconv.ParseInt(*port, 10, 64)
+		if err != nil || value < 1 || value > 65535 {
+			return fmt.Errorf("%s should be a port number: %s", keys.healthCheckPort, *port)
+		}
+		healthCheck.Port = value
+	}
+
+	if healthCheck.Type != route53.HealthCheckTypeTcp {
+		healthCheck.ResourcePath = aws.String("/")
+		if path != nil {
+			healthCheck.ResourcePath = path
+		}
+	}
+
+	if !config.IsPublic {
+		return fmt.Errorf("%s: Route 53 health checks can only reach public addresses", keys.healthCheckType)
+	}
+
+	if config.SharedRecords {
+		return fmt.Errorf("%s cannot be combined with %s", keys.healthCheckType, keys.sharedRecords)
+	}
+
+	if config.RecordType == RecordTypeCNAME {
+		return fmt.Errorf("%s cannot be used with %s record type", keys.healthCheckType, RecordTypeCNAME)
+	}
+
+	// Each instance would overwrite the record set of the previous one, orphaning its health check
+	if config.RoutingPolicy == RoutingPolicySimple {
+		return fmt.Errorf("%s cannot be used with %s routing policy", keys.healthCheckType, RoutingPolicySimple)
+	}
+
+	// One health check is attached to the records, so they must hold a single address
+	if config.AddressFamily == AddressFamilyDual {
+		return fmt.Errorf("%s cannot be used with %s %s", keys.healthCheckType, keys.addressFamily, AddressFamilyDual)
+	}
+	if config.AddressSource == AddressSourceAllPrivate {
+		return fmt.Errorf("%s cannot be used with %s %s", keys.healthCheckType, keys.addressSource, AddressSourceAllPrivate)
+	}
+
+	config.HealthCheck = healthCheck
+
+	return nil
+}
+
+// loadTTLs reads the TTL of the zone configuration and TTLs of individual records, which are
+// tagged with the record name appended to the TTL tag key, e.g. private-ttl:api.example.com
+func (l Route53ZoneConfigLoader) loadTTLs(tags *[]*ec2.Tag, keys zoneTagKeys, config *Route53ZoneConfig) error {
+	if value := l.findValueFromEC2Tags(tags, keys.ttl); value != nil {
+		ttl, err := parseTTL(*value)
+		if err != nil {
+			return fmt.Errorf("invalid value for %s: %w", keys.ttl, err)
+		}
+		config.TTL = aws.Int64(ttl)
+	}
+
+	recordTTLPrefix := keys.ttl + ":"
+	for _, tag := range *tags {
+		if !strings.HasPrefix(*tag.Key, recordTTLPrefix) {
+			continue
+		}
+
+		record := strings.TrimPrefix(*tag.Key, recordTTLPrefix)
+		found := false
+		for _, r := range config.DNSRecords {
+			found = found || r == record
+		}
+		if !found {
+			return fmt.Errorf("%s refers to a record not listed in %s", *tag.Key, keys.dnsRecords)
+		}
+
+		ttl, err := parseTTL(aws.StringValue(tag.Value))
+		if err != nil {
+			return fmt.Errorf("invalid value for %s: %w", *tag.Key, err)
+		}
+		if config.RecordTTLs == nil {
+			config.RecordTTLs = map[string]int64{}
+		}
+		config.RecordTTLs[record] = ttl
+	}
+
+	return nil
+}
+
+func parseTTL(value string) (int64, error) {
+	ttl, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || ttl < 0 || ttl > maxRoute53TTL {
+		return 0, fmt.Errorf("TTL should be an integer between 0 and %d: %s", maxRoute53TTL, value)
+	}
+
+	return ttl, nil
+}
+
+// TTLFor returns the TTL of the record, falling back to the TTL of the configuration
+func (c *Route53ZoneConfig) TTLFor(record string) int64 {
+	if ttl, ok := c.RecordTTLs[record]; ok {
+		return ttl
+	}
+	if c.TTL != nil {
+		return *c.TTL
+	}
+
+	return defaultTTL
+}
+
+func (c *Route53ZoneConfig) maxTTL() int64 {
+	ttl := c.TTLFor("")
+	for _, recordTTL := range c.RecordTTLs {
+		if recordTTL > ttl {
+			ttl = recordTTL
+		}
+	}
+
+	return ttl
 }
 
 // MultiValueAnswer returns true if the record needs to be inserted with multi value answer option
 func (c *Route53ZoneConfig) MultiValueAnswer() *bool {
-	if c.SetIdentifier != nil {
+	if c.RoutingPolicy == RoutingPolicyMultivalue || (c.RoutingPolicy == "" && c.SetIdentifier != nil) {
 		return aws.Bool(true)
 	}
 
 	return nil
 }
+
+// RecordTypes returns the record types to maintain for the configured record type and address family
+func (c *Route53ZoneConfig) RecordTypes() []string {
+	if c.RecordType == RecordTypeCNAME {
+		return []string{"CNAME"}
+	}
+
+	switch c.AddressFamily {
+	case AddressFamilyIPv6:
+		return []string{"AAAA"}
+	case AddressFamilyDual:
+		return []string{"A", "AAAA"}
+	default:
+		return []string{"A"}
+	}
+}
+
+// NeedsPublicIPv4 returns true if the records of the config point at the public IPv4 address, or
+// at the public DNS name which only exists along with it
+func (c *Route53ZoneConfig) NeedsPublicIPv4() bool {
+	if c.RecordType == RecordTypeCNAME {
+		return c.IsPublic
+	}
+
+	return c.IsPublic && c.AddressFamily != AddressFamilyIPv6 &&
+		(c.AddressSource == "" || c.AddressSource == AddressSourcePrimary) && !c.SelectsNetworkInterface()
+}
+
+// ownerRecordName returns the name of the TXT ownership record of a record
+func (c *Route53ZoneConfig) ownerRecordName(name string) string {
+	if c.RecordType == RecordTypeCNAME {
+		return cnameOwnerPrefix + name
+	}
+
+	return name
+}
+
+// CheckCNAMEConflicts returns an error if a CNAME record of one config has the name of a record of
+// another config in the same hosted zone. Route 53 rejects a CNAME next to other records, which
+// loadRecordType can only rule out within one config. Names are compared as expanded for the
+// instance, before any record is changed.
+func CheckCNAMEConflicts(configs []*Route53ZoneConfig, ec2Instance *ec2.Instance) error {
+	type zoneRecord struct {
+		hostedZoneID string
+		name         string
+	}
+
+	var cnames []zoneRecord
+	others := map[zoneRecord]bool{}
+	for _, config := range configs {
+		expanded, err := config.forInstance(ec2Instance)
+		if err != nil {
+			return err
+		}
+
+		// Tagged IDs may carry the prefix that inferred IDs are stripped of
+		hostedZoneID := trimHostedZoneIDPrefix(expanded.HostedZoneID)
+		for _, record := range expanded.DNSRecords {
+			key := zoneRecord{hostedZoneID, normalizeRecordName(record)}
+			if expanded.RecordType == RecordTypeCNAME {
+				cnames = append(cnames, key)
+			} else {
+				others[key] = true
+			}
+		}
+		if expanded.SRV != nil {
+			others[zoneRecord{hostedZoneID, normalizeRecordName(expanded.SRV.Name)}] = true
+		}
+	}
+
+	for _, cname := range cnames {
+		if others[cname] {
+			return fmt.Errorf("%s record %s cannot be combined with other records of the same name in hosted zone %s",
+				RecordTypeCNAME, cname.name, cname.hostedZoneID)
+		}
+	}
+
+	return nil
+}
+
+// trimHostedZoneIDPrefix removes the /hostedzone/ prefix that Route 53 returns with hosted zone IDs
+func trimHostedZoneIDPrefix(hostedZoneID string) string {
+	return strings.TrimPrefix(hostedZoneID, "/hostedzone/")
+}
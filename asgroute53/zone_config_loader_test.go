@@ -3,6 +3,7 @@ package asgroute53
 import (
 	"errors"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -59,6 +60,94 @@ func Test_Load(t *testing.T) {
 			Value: aws.String("PRIVATE-ZONE-ID"),
 		},
 	}
+	dualStackTags := &[]*ec2.Tag{
+		{
+			Key:   aws.String(privateHostedZoneIDKey),
+			Value: aws.String("PRIVATE-ZONE-ID"),
+		},
+		{
+			Key:   aws.String(privateDNSRecordsKey),
+			Value: aws.String("private.example.com"),
+		},
+		{
+			Key:   aws.String(privateAddressFamilyKey),
+			Value: aws.String(AddressFamilyDual),
+		},
+		{
+			Key:   aws.String(publicHostedZoneIDKey),
+			Value: aws.String("PUBLIC-ZONE-ID"),
+		},
+		{
+			Key:   aws.String(publicDNSRecordsKey),
+			Value: aws.String("public.example.com"),
+		},
+		{
+			Key:   aws.String(publicAddressFamilyKey),
+			Value: aws.String("ipv5"),
+		},
+	}
+	mixedCaseTags := &[]*ec2.Tag{
+		{
+			Key:   aws.String(privateHostedZoneIDKey),
+			Value: aws.String("PRIVATE-ZONE-ID"),
+		},
+		{
+			Key:   aws.String(privateDNSRecordsKey),
+			Value: aws.String("private.example.com"),
+		},
+		{
+			Key:   aws.String(privateAddressFamilyKey),
+			Value: aws.String("IPv6"),
+		},
+	}
+	sharedRecordsTags := &[]*ec2.Tag{
+		{
+			Key:   aws.String(privateHostedZoneIDKey),
+			Value: aws.String("PRIVATE-ZONE-ID"),
+		},
+		{
+			Key:   aws.String(privateDNSRecordsKey),
+			Value: aws.String("pool.example.com"),
+		},
+		{
+			Key:   aws.String(privateSharedRecordsKey),
+			Value: aws.String("true"),
+		},
+		{
+			Key:   aws.String(publicHostedZoneIDKey),
+			Value: aws.String("PUBLIC-ZONE-ID"),
+		},
+		{
+			Key:   aws.String(publicDNSRecordsKey),
+			Value: aws.String("pool.example.com"),
+		},
+		{
+			Key:   aws.String(publicSharedRecordsKey),
+			Value: aws.String("true"),
+		},
+		{
+			Key:   aws.String(publicSetIdentifierKey),
+			Value: aws.String("identifier"),
+		},
+	}
+	templatedTags := &[]*ec2.Tag{
+		{
+			Key:   aws.String(privateHostedZoneIDKey),
+			Value: aws.String("PRIVATE-ZONE-ID"),
+		},
+		{
+			Key:   aws.String(privateDNSRecordsKey),
+			Value: aws.String("pool.example.com,{instance-id}.nodes.example.com"),
+		},
+		{
+			Key:   aws.String(publicHostedZoneIDKey),
+			Value: aws.String("PUBLIC-ZONE-ID"),
+		},
+		{
+			Key:   aws.String(publicDNSRecordsKey),
+			Value: aws.String("{hostname}.example.com"),
+		},
+	}
 	type args struct {
 		tags     *[]*ec2.Tag
 		isPublic bool
@@ -86,6 +175,10 @@ func Test_Load(t *testing.T) {
 				DNSRecords:    []string{"private0.example.com", "private1.example.com"},
 				SetIdentifier: privateSetIdentifier,
 				IsPublic:      false,
+				AddressFamily: AddressFamilyIPv4,
+				AddressSource: AddressSourcePrimary,
+				RecordType:    RecordTypeAddress,
+				RoutingPolicy: RoutingPolicyMultivalue,
 			},
 			wantErr: false,
 		},
@@ -105,6 +198,10 @@ func Test_Load(t *testing.T) {
 				DNSRecords:    []string{"public0.example.com", "public1.example.com"},
 				SetIdentifier: publicSetIdentifier,
 				IsPublic:      true,
+				AddressFamily: AddressFamilyIPv4,
+				AddressSource: AddressSourcePrimary,
+				RecordType:    RecordTypeAddress,
+				RoutingPolicy: RoutingPolicyMultivalue,
 			},
 			wantErr: false,
 		},
@@ -176,6 +273,138 @@ func Test_Load(t *testing.T) {
 			want:    nil,
 			wantErr: true,
 		},
+		{
+			name: "private-dual-stack",
+			l: NewZoneConfigLoader(&mockedRoute53{
+				getHostedZoneOutput: &route53.GetHostedZoneOutput{
+					HostedZone: &route53.HostedZone{},
+				},
+			}),
+			args: args{
+				tags:     dualStackTags,
+				isPublic: false,
+			},
+			want: &Route53ZoneConfig{
+				HostedZoneID:  "PRIVATE-ZONE-ID",
+				DNSRecords:    []string{"private.example.com"},
+				IsPublic:      false,
+				AddressFamily: AddressFamilyDual,
+				AddressSource: AddressSourcePrimary,
+				RecordType:    RecordTypeAddress,
+				RoutingPolicy: RoutingPolicySimple,
+			},
+			wantErr: false,
+		},
+		{
+			name: "private-mixed-case-address-family",
+			l: NewZoneConfigLoader(&mockedRoute53{
+				getHostedZoneOutput: &route53.GetHostedZoneOutput{
+					HostedZone: &route53.HostedZone{},
+				},
+			}),
+			args: args{
+				tags:     mixedCaseTags,
+				isPublic: false,
+			},
+			want: &Route53ZoneConfig{
+				HostedZoneID:  "PRIVATE-ZONE-ID",
+				DNSRecords:    []string{"private.example.com"},
+				IsPublic:      false,
+				AddressFamily: AddressFamilyIPv6,
+				AddressSource: AddressSourcePrimary,
+				RecordType:    RecordTypeAddress,
+				RoutingPolicy: RoutingPolicySimple,
+			},
+			wantErr: false,
+		},
+		{
+			name: "public-invalid-address-family",
+			l: NewZoneConfigLoader(&mockedRoute53{
+				getHostedZoneOutput: &route53.GetHostedZoneOutput{
+					HostedZone: &route53.HostedZone{},
+				},
+			}),
+			args: args{
+				tags:     dualStackTags,
+				isPublic: true,
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "private-shared-records",
+			l: NewZoneConfigLoader(&mockedRoute53{
+				getHostedZoneOutput: &route53.GetHostedZoneOutput{
+					HostedZone: &route53.HostedZone{},
+				},
+			}),
+			args: args{
+				tags:     sharedRecordsTags,
+				isPublic: false,
+			},
+			want: &Route53ZoneConfig{
+				HostedZoneID:  "PRIVATE-ZONE-ID",
+				DNSRecords:    []string{"pool.example.com"},
+				IsPublic:      false,
+				AddressFamily: AddressFamilyIPv4,
+				AddressSource: AddressSourcePrimary,
+				RecordType:    RecordTypeAddress,
+				RoutingPolicy: RoutingPolicySimple,
+				SharedRecords: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "public-shared-records-with-set-identifier",
+			l: NewZoneConfigLoader(&mockedRoute53{
+				getHostedZoneOutput: &route53.GetHostedZoneOutput{
+					HostedZone: &route53.HostedZone{},
+				},
+			}),
+			args: args{
+				tags:     sharedRecordsTags,
+				isPublic: true,
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "private-templated",
+			l: NewZoneConfigLoader(&mockedRoute53{
+				getHostedZoneOutput: &route53.GetHostedZoneOutput{
+					HostedZone: &route53.HostedZone{},
+				},
+			}),
+			args: args{
+				tags:     templatedTags,
+				isPublic: false,
+			},
+			want: &Route53ZoneConfig{
+				HostedZoneID:  "PRIVATE-ZONE-ID",
+				DNSRecords:    []string{"pool.example.com", "{instance-id}.nodes.example.com"},
+				SetIdentifier: aws.String(instanceIDTemplate),
+				IsPublic:      false,
+				AddressFamily: AddressFamilyIPv4,
+				AddressSource: AddressSourcePrimary,
+				RecordType:    RecordTypeAddress,
+				RoutingPolicy: RoutingPolicyMultivalue,
+			},
+			wantErr: false,
+		},
+		{
+			name: "public-unknown-placeholder",
+			l: NewZoneConfigLoader(&mockedRoute53{
+				getHostedZoneOutput: &route53.GetHostedZoneOutput{
+					HostedZone: &route53.HostedZone{},
+				},
+			}),
+			args: args{
+				tags:     templatedTags,
+				isPublic: true,
+			},
+			want:    nil,
+			wantErr: true,
+		},
 		{
 			name: "private-empty",
 			l:    NewZoneConfigLoader(&mockedRoute53{}),
@@ -199,14 +428,709 @@ func Test_Load(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := tt.l.Load(tt.args.tags, tt.args.isPublic)
+			got, err := tt.l.Load(tt.args.tags, tt.args.isPublic, nil)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Load() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
-			if !reflect.DeepEqual(got, tt.want) {
+			var want []*Route53ZoneConfig
+			if tt.want != nil {
+				want = []*Route53ZoneConfig{tt.want}
+			}
+			if !reflect.DeepEqual(got, want) {
 				t.Errorf("Load() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
+
+func Test_loadRoutingPolicy(t *testing.T) {
+	newTags := func(values map[string]string) *[]*ec2.Tag {
+		tags := []*ec2.Tag{}
+		for key, value := range values {
+			tags = append(tags, &ec2.Tag{
+				Key:   aws.String(key),
+				Value: aws.String(value),
+			})
+		}
+		return &tags
+	}
+	tests := []struct {
+		name          string
+		tags          map[string]string
+		setIdentifier *string
+		want          *Route53ZoneConfig
+		wantErr       bool
+	}{
+		{
+			name: "default-simple",
+			tags: map[string]string{},
+			want: &Route53ZoneConfig{
+				RoutingPolicy: RoutingPolicySimple,
+			},
+			wantErr: false,
+		},
+		{
+			name:          "default-multivalue",
+			tags:          map[string]string{},
+			setIdentifier: aws.String("identifier"),
+			want: &Route53ZoneConfig{
+				SetIdentifier: aws.String("identifier"),
+				RoutingPolicy: RoutingPolicyMultivalue,
+			},
+			wantErr: false,
+		},
+		{
+			name: "weighted",
+			tags: map[string]string{
+				privateRoutingPolicyKey: "weighted",
+				privateWeightKey:        "20",
+			},
+			want: &Route53ZoneConfig{
+				SetIdentifier: aws.String(instanceIDTemplate),
+				RoutingPolicy: RoutingPolicyWeighted,
+				Weight:        aws.Int64(20),
+			},
+			wantErr: false,
+		},
+		{
+			name: "weighted-out-of-range",
+			tags: map[string]string{
+				privateRoutingPolicyKey: "weighted",
+				privateWeightKey:        "256",
+			},
+			wantErr: true,
+		},
+		{
+			name: "latency",
+			tags: map[string]string{
+				privateRoutingPolicyKey: "latency",
+				privateRegionKey:        "ap-northeast-1",
+			},
+			setIdentifier: aws.String("tokyo"),
+			want: &Route53ZoneConfig{
+				SetIdentifier: aws.String("tokyo"),
+				RoutingPolicy: RoutingPolicyLatency,
+				Region:        aws.String("ap-northeast-1"),
+			},
+			wantErr: false,
+		},
+		{
+			name: "latency-region-missing",
+			tags: map[string]string{
+				privateRoutingPolicyKey: "latency",
+			},
+			wantErr: true,
+		},
+		{
+			name: "geolocation-country",
+			tags: map[string]string{
+				privateRoutingPolicyKey:          "geolocation",
+				privateGeoLocationCountryKey:     "US",
+				privateGeoLocationSubdivisionKey: "CA",
+			},
+			want: &Route53ZoneConfig{
+				SetIdentifier: aws.String(instanceIDTemplate),
+				RoutingPolicy: RoutingPolicyGeolocation,
+				GeoLocation: &route53.GeoLocation{
+					CountryCode:     aws.String("US"),
+					SubdivisionCode: aws.String("CA"),
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "geolocation-continent-and-country",
+			tags: map[string]string{
+				privateRoutingPolicyKey:        "geolocation",
+				privateGeoLocationContinentKey: "EU",
+				privateGeoLocationCountryKey:   "DE",
+			},
+			wantErr: true,
+		},
+		{
+			name: "failover",
+			tags: map[string]string{
+				privateRoutingPolicyKey: "failover",
+				privateFailoverKey:      "primary",
+			},
+			want: &Route53ZoneConfig{
+				SetIdentifier: aws.String(instanceIDTemplate),
+				RoutingPolicy: RoutingPolicyFailover,
+				Failover:      aws.String("PRIMARY"),
+			},
+			wantErr: false,
+		},
+		{
+			name: "failover-invalid",
+			tags: map[string]string{
+				privateRoutingPolicyKey: "failover",
+				privateFailoverKey:      "tertiary",
+			},
+			wantErr: true,
+		},
+		{
+			name: "parameter-for-other-policy",
+			tags: map[string]string{
+				privateRoutingPolicyKey: "multivalue",
+				privateWeightKey:        "10",
+			},
+			wantErr: true,
+		},
+		{
+			name:          "simple-with-set-identifier",
+			tags:          map[string]string{privateRoutingPolicyKey: "simple"},
+			setIdentifier: aws.String("identifier"),
+			wantErr:       true,
+		},
+		{
+			name:    "unsupported",
+			tags:    map[string]string{privateRoutingPolicyKey: "random"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := &Route53ZoneConfig{
+				SetIdentifier: tt.setIdentifier,
+			}
+			err := NewZoneConfigLoader(&mockedRoute53{}).loadRoutingPolicy(newTags(tt.tags), privateZoneTagKeys, got)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("loadRoutingPolicy() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("loadRoutingPolicy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_loadHealthCheck(t *testing.T) {
+	tests := []struct {
+		name          string
+		tags          *[]*ec2.Tag
+		isPublic      bool
+		addressFamily string
+		addressSource string
+		routingPolicy string
+		want          *HealthCheckConfig
+		wantErr       bool
+	}{
+		{
+			name:     "none",
+			tags:     &[]*ec2.Tag{},
+			isPublic: true,
+			want:     nil,
+			wantErr:  false,
+		},
+		{
+			name: "https-default-port",
+			tags: &[]*ec2.Tag{
+				{Key: aws.String(publicHealthCheckTypeKey), Value: aws.String("https")},
+				{Key: aws.String(publicHealthCheckPathKey), Value: aws.String("/health")},
+			},
+			isPublic: true,
+			want: &HealthCheckConfig{
+				Type:         route53.HealthCheckTypeHttps,
+				Port:         443,
+				ResourcePath: aws.String("/health"),
+			},
+			wantErr: false,
+		},
+		{
+			name: "tcp",
+			tags: &[]*ec2.Tag{
+				{Key: aws.String(publicHealthCheckTypeKey), Value: aws.String("TCP")},
+				{Key: aws.String(publicHealthCheckPortKey), Value: aws.String("5432")},
+			},
+			isPublic: true,
+			want: &HealthCheckConfig{
+				Type: route53.HealthCheckTypeTcp,
+				Port: 5432,
+			},
+			wantErr: false,
+		},
+		{
+			name: "tcp-port-missing",
+			tags: &[]*ec2.Tag{
+				{Key: aws.String(publicHealthCheckTypeKey), Value: aws.String("TCP")},
+			},
+			isPublic: true,
+			wantErr:  true,
+		},
+		{
+			name: "port-without-type",
+			tags: &[]*ec2.Tag{
+				{Key: aws.String(publicHealthCheckPortKey), Value: aws.String("80")},
+			},
+			isPublic: true,
+			wantErr:  true,
+		},
+		{
+			name: "private",
+			tags: &[]*ec2.Tag{
+				{Key: aws.String(privateHealthCheckTypeKey), Value: aws.String("HTTP")},
+			},
+			isPublic: false,
+			wantErr:  true,
+		},
+		{
+			name: "dual",
+			tags: &[]*ec2.Tag{
+				{Key: aws.String(publicHealthCheckTypeKey), Value: aws.String("HTTP")},
+			},
+			isPublic:      true,
+			addressFamily: AddressFamilyDual,
+			wantErr:       true,
+		},
+		{
+			name: "all-private",
+			tags: &[]*ec2.Tag{
+				{Key: aws.String(publicHealthCheckTypeKey), Value: aws.String("HTTP")},
+			},
+			isPublic:      true,
+			addressSource: AddressSourceAllPrivate,
+			wantErr:       true,
+		},
+		{
+			name: "simple-routing",
+			tags: &[]*ec2.Tag{
+				{Key: aws.String(publicHealthCheckTypeKey), Value: aws.String("HTTP")},
+			},
+			isPublic:      true,
+			routingPolicy: RoutingPolicySimple,
+			wantErr:       true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := privateZoneTagKeys
+			if tt.isPublic {
+				keys = publicZoneTagKeys
+			}
+			config := &Route53ZoneConfig{
+				IsPublic:      tt.isPublic,
+				AddressFamily: tt.addressFamily,
+				AddressSource: tt.addressSource,
+				RoutingPolicy: tt.routingPolicy,
+			}
+			err := NewZoneConfigLoader(&mockedRoute53{}).loadHealthCheck(tt.tags, keys, config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("loadHealthCheck() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(config.HealthCheck, tt.want) {
+				t.Errorf("loadHealthCheck() = %v, want %v", config.HealthCheck, tt.want)
+			}
+		})
+	}
+}
+
+func Test_loadTTLs(t *testing.T) {
+	tests := []struct {
+		name    string
+		tags    *[]*ec2.Tag
+		want    map[string]int64
+		wantErr bool
+	}{
+		{
+			name: "default",
+			tags: &[]*ec2.Tag{},
+			want: map[string]int64{
+				"pool.example.com":                defaultTTL,
+				"{instance-id}.nodes.example.com": defaultTTL,
+			},
+			wantErr: false,
+		},
+		{
+			name: "zone-and-record",
+			tags: &[]*ec2.Tag{
+				{Key: aws.String(privateTTLKey), Value: aws.String("300")},
+				{Key: aws.String(privateTTLKey + ":pool.example.com"), Value: aws.String("30")},
+			},
+			want: map[string]int64{
+				"pool.example.com":                30,
+				"{instance-id}.nodes.example.com": 300,
+			},
+			wantErr: false,
+		},
+		{
+			name: "unknown-record",
+			tags: &[]*ec2.Tag{
+				{Key: aws.String(privateTTLKey + ":other.example.com"), Value: aws.String("30")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative",
+			tags: &[]*ec2.Tag{
+				{Key: aws.String(privateTTLKey), Value: aws.String("-1")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "too-large",
+			tags: &[]*ec2.Tag{
+				{Key: aws.String(privateTTLKey), Value: aws.String("2147483648")},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Route53ZoneConfig{
+				DNSRecords: []string{"pool.example.com", "{instance-id}.nodes.example.com"},
+			}
+			err := NewZoneConfigLoader(&mockedRoute53{}).loadTTLs(tt.tags, privateZoneTagKeys, config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("loadTTLs() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+
+			expanded, err := config.forInstance(&ec2.Instance{InstanceId: aws.String("i-1")})
+			if err != nil {
+				t.Fatalf("Route53ZoneConfig.forInstance() error = %v", err)
+			}
+			for record, want := range tt.want {
+				if got := config.TTLFor(record); got != want {
+					t.Errorf("Route53ZoneConfig.TTLFor(%s) = %v, want %v", record, got, want)
+				}
+			}
+			if got, want := expanded.TTLFor("i-1.nodes.example.com"), tt.want["{instance-id}.nodes.example.com"]; got != want {
+				t.Errorf("expanded Route53ZoneConfig.TTLFor() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func Test_loadAddressSelection(t *testing.T) {
+	tests := []struct {
+		name      string
+		tags      *[]*ec2.Tag
+		wantIndex *int64
+		wantTag   *ec2.Tag
+		wantErr   bool
+	}{
+		{
+			name:    "default",
+			tags:    &[]*ec2.Tag{},
+			wantErr: false,
+		},
+		{
+			name: "device-index",
+			tags: &[]*ec2.Tag{
+				{Key: aws.String(privateAddressSourceKey), Value: aws.String("Secondary")},
+				{Key: aws.String(privateNetworkInterfaceIndexKey), Value: aws.String("1")},
+			},
+			wantIndex: aws.Int64(1),
+			wantErr:   false,
+		},
+		{
+			name: "interface-tag",
+			tags: &[]*ec2.Tag{
+				{Key: aws.String(privateNetworkInterfaceTagKey), Value: aws.String("role=data")},
+			},
+			wantTag: &ec2.Tag{Key: aws.String("role"), Value: aws.String("data")},
+			wantErr: false,
+		},
+		{
+			name: "invalid-source",
+			tags: &[]*ec2.Tag{
+				{Key: aws.String(privateAddressSourceKey), Value: aws.String("tertiary")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid-index",
+			tags: &[]*ec2.Tag{
+				{Key: aws.String(privateNetworkInterfaceIndexKey), Value: aws.String("-1")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid-interface-tag",
+			tags: &[]*ec2.Tag{
+				{Key: aws.String(privateNetworkInterfaceTagKey), Value: aws.String("role")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "index-and-tag",
+			tags: &[]*ec2.Tag{
+				{Key: aws.String(privateNetworkInterfaceIndexKey), Value: aws.String("1")},
+				{Key: aws.String(privateNetworkInterfaceTagKey), Value: aws.String("role=data")},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Route53ZoneConfig{
+				AddressSource: AddressSourcePrimary,
+			}
+			err := NewZoneConfigLoader(&mockedRoute53{}).loadAddressSelection(tt.tags, privateZoneTagKeys, config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("loadAddressSelection() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(config.NetworkInterfaceIndex, tt.wantIndex) {
+				t.Errorf("loadAddressSelection() index = %v, want %v", config.NetworkInterfaceIndex, tt.wantIndex)
+			}
+			if !reflect.DeepEqual(config.NetworkInterfaceTag, tt.wantTag) {
+				t.Errorf("loadAddressSelection() tag = %v, want %v", config.NetworkInterfaceTag, tt.wantTag)
+			}
+		})
+	}
+}
+
+func Test_loadRecordType(t *testing.T) {
+	tests := []struct {
+		name              string
+		tags              *[]*ec2.Tag
+		records           []string
+		wantRoutingPolicy string
+		wantErr           bool
+	}{
+		{
+			name: "cname",
+			tags: &[]*ec2.Tag{
+				{Key: aws.String(privateRecordTypeKey), Value: aws.String("CNAME")},
+			},
+			records:           []string{"{instance-id}.nodes.example.com"},
+			wantRoutingPolicy: RoutingPolicySimple,
+			wantErr:           false,
+		},
+		{
+			name: "cname-weighted",
+			tags: &[]*ec2.Tag{
+				{Key: aws.String(privateRecordTypeKey), Value: aws.String("cname")},
+				{Key: aws.String(privateRoutingPolicyKey), Value: aws.String(RoutingPolicyWeighted)},
+				{Key: aws.String(privateWeightKey), Value: aws.String("10")},
+			},
+			records:           []string{"api.example.com"},
+			wantRoutingPolicy: RoutingPolicyWeighted,
+			wantErr:           false,
+		},
+		{
+			name: "cname-multivalue",
+			tags: &[]*ec2.Tag{
+				{Key: aws.String(privateRecordTypeKey), Value: aws.String("cname")},
+				{Key: aws.String(privateSetIdentifierKey), Value: aws.String("identifier")},
+			},
+			records: []string{"api.example.com"},
+			wantErr: true,
+		},
+		{
+			name: "cname-address-family",
+			tags: &[]*ec2.Tag{
+				{Key: aws.String(privateRecordTypeKey), Value: aws.String("cname")},
+				{Key: aws.String(privateAddressFamilyKey), Value: aws.String(AddressFamilyDual)},
+			},
+			records: []string{"api.example.com"},
+			wantErr: true,
+		},
+		{
+			name: "cname-shared",
+			tags: &[]*ec2.Tag{
+				{Key: aws.String(privateRecordTypeKey), Value: aws.String("cname")},
+				{Key: aws.String(privateSharedRecordsKey), Value: aws.String("true")},
+			},
+			records: []string{"api.example.com"},
+			wantErr: true,
+		},
+		{
+			name: "invalid",
+			tags: &[]*ec2.Tag{
+				{Key: aws.String(privateRecordTypeKey), Value: aws.String("mx")},
+			},
+			records: []string{"api.example.com"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tags := append([]*ec2.Tag{
+				{Key: aws.String(privateHostedZoneIDKey), Value: aws.String("ID")},
+				{Key: aws.String(privateDNSRecordsKey), Value: aws.String(strings.Join(tt.records, ","))},
+			}, *tt.tags...)
+			l := NewZoneConfigLoader(&mockedRoute53{
+				getHostedZoneOutput: &route53.GetHostedZoneOutput{
+					HostedZone: &route53.HostedZone{},
+				},
+			})
+			configs, err := l.Load(&tags, false, nil)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Load() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+			got := configs[0]
+			if got.RecordType != RecordTypeCNAME || !reflect.DeepEqual(got.RecordTypes(), []string{"CNAME"}) {
+				t.Errorf("Load() RecordType = %v, want %v", got.RecordType, RecordTypeCNAME)
+			}
+			if got.RoutingPolicy != tt.wantRoutingPolicy {
+				t.Errorf("Load() RoutingPolicy = %v, want %v", got.RoutingPolicy, tt.wantRoutingPolicy)
+			}
+		})
+	}
+}
+
+func Test_LoadAll(t *testing.T) {
+	tests := []struct {
+		name        string
+		tags        *[]*ec2.Tag
+		wantZoneIDs []string
+		wantErr     bool
+	}{
+		{
+			name:        "none",
+			tags:        &[]*ec2.Tag{},
+			wantZoneIDs: nil,
+			wantErr:     false,
+		},
+		{
+			name: "indexed",
+			tags: &[]*ec2.Tag{
+				{Key: aws.String(privateHostedZoneIDKey + ".2"), Value: aws.String("APP-ZONE-ID")},
+				{Key: aws.String(privateDNSRecordsKey + ".2"), Value: aws.String("api.app.internal")},
+				{Key: aws.String(privateTTLKey + ".2:api.app.internal"), Value: aws.String("30")},
+				{Key: aws.String(privateHostedZoneIDKey), Value: aws.String("ZONE-ID")},
+				{Key: aws.String(privateDNSRecordsKey), Value: aws.String("api.example.com")},
+				{Key: aws.String(privateHostedZoneIDKey + ".1"), Value: aws.String("SHARED-ZONE-ID")},
+				{Key: aws.String(privateDNSRecordsKey + ".1"), Value: aws.String("api.shared.internal")},
+				{Key: aws.String(privateSetIdentifierKey + ".1"), Value: aws.String("{instance-id}")},
+			},
+			wantZoneIDs: []string{"ZONE-ID", "SHARED-ZONE-ID", "APP-ZONE-ID"},
+			wantErr:     false,
+		},
+		{
+			name: "indexed-records-missing",
+			tags: &[]*ec2.Tag{
+				{Key: aws.String(privateHostedZoneIDKey + ".1"), Value: aws.String("SHARED-ZONE-ID")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid-index",
+			tags: &[]*ec2.Tag{
+				{Key: aws.String(privateHostedZoneIDKey + ".a"), Value: aws.String("SHARED-ZONE-ID")},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewZoneConfigLoader(&mockedRoute53{
+				getHostedZoneOutput: &route53.GetHostedZoneOutput{
+					HostedZone: &route53.HostedZone{},
+				},
+			})
+			got, err := l.LoadAll(tt.tags, false, nil)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LoadAll() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			var zoneIDs []string
+			for _, config := range got {
+				zoneIDs = append(zoneIDs, config.HostedZoneID)
+			}
+			if !reflect.DeepEqual(zoneIDs, tt.wantZoneIDs) {
+				t.Errorf("LoadAll() zones = %v, want %v", zoneIDs, tt.wantZoneIDs)
+			}
+			if len(got) == 3 {
+				if got[1].RoutingPolicy != RoutingPolicyMultivalue || got[2].TTLFor("api.app.internal") != 30 {
+					t.Errorf("LoadAll() indexed options were not applied: %+v, %+v", got[1], got[2])
+				}
+			}
+		})
+	}
+}
+
+func TestCheckCNAMEConflicts(t *testing.T) {
+	newConfig := func(zoneID string, recordType string, records ...string) *Route53ZoneConfig {
+		return &Route53ZoneConfig{
+			HostedZoneID: zoneID,
+			DNSRecords:   records,
+			RecordType:   recordType,
+		}
+	}
+	instance := &ec2.Instance{
+		InstanceId: aws.String("i-1"),
+	}
+	tests := []struct {
+		name    string
+		configs []*Route53ZoneConfig
+		wantErr bool
+	}{
+		{
+			name: "distinct-names",
+			configs: []*Route53ZoneConfig{
+				newConfig("ID", RecordTypeCNAME, "www.example.com"),
+				newConfig("ID", RecordTypeAddress, "api.example.com"),
+			},
+			wantErr: false,
+		},
+		{
+			name: "other-zone",
+			configs: []*Route53ZoneConfig{
+				newConfig("ID", RecordTypeCNAME, "www.example.com"),
+				newConfig("OTHER-ID", RecordTypeAddress, "www.example.com"),
+			},
+			wantErr: false,
+		},
+		{
+			name: "address-record",
+			configs: []*Route53ZoneConfig{
+				newConfig("ID", RecordTypeCNAME, "www.example.com"),
+				newConfig("ID", RecordTypeAddress, "api.example.com", "WWW.example.com."),
+			},
+			wantErr: true,
+		},
+		{
+			name: "prefixed-zone-id",
+			configs: []*Route53ZoneConfig{
+				newConfig("/hostedzone/ID", RecordTypeCNAME, "www.example.com"),
+				newConfig("ID", RecordTypeAddress, "www.example.com"),
+			},
+			wantErr: true,
+		},
+		{
+			name: "expanded-names",
+			configs: []*Route53ZoneConfig{
+				newConfig("ID", RecordTypeAddress, "i-1.example.com"),
+				newConfig("ID", RecordTypeCNAME, "{instance-id}.example.com"),
+			},
+			wantErr: true,
+		},
+		{
+			name: "srv-record",
+			configs: []*Route53ZoneConfig{
+				newConfig("ID", RecordTypeCNAME, "_http._tcp.example.com"),
+				{
+					HostedZoneID: "ID",
+					DNSRecords:   []string{"{instance-id}.example.com"},
+					RecordType:   RecordTypeAddress,
+					SRV:          &SRVConfig{Name: "_http._tcp.example.com"},
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := CheckCNAMEConflicts(tt.configs, instance); (err != nil) != tt.wantErr {
+				t.Errorf("CheckCNAMEConflicts() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package asgroute53
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+)
+
+// inferHostedZones splits the records of a config without a hosted zone ID into one config per
+// hosted zone, found by the longest zone name matching each record
+func (l Route53ZoneConfigLoader) inferHostedZones(config *Route53ZoneConfig, vpcID *string) ([]*Route53ZoneConfig, error) {
+	cache := map[string]*route53.HostedZone{}
+	byZoneID := map[string]*Route53ZoneConfig{}
+	var configs []*Route53ZoneConfig
+
+	for _, record := range config.DNSRecords {
+		hostedZone, err := l.findHostedZone(record, config.IsPublic, vpcID, cache)
+		if err != nil {
+			return nil, err
+		}
+
+		zoneID := trimHostedZoneIDPrefix(aws.StringValue(hostedZone.Id))
+		zoneConfig, ok := byZoneID[zoneID]
+		if !ok {
+			zoneConfig = config.withHostedZone(zoneID, hostedZone.Name)
+			// A PTR record can only point at one name, so only the first zone maintains them
+			if len(configs) > 0 {
+				zoneConfig.ReverseHostedZoneID = nil
+			}
+			byZoneID[zoneID] = zoneConfig
+			configs = append(configs, zoneConfig)
+		}
+
+		zoneConfig.DNSRecords = append(zoneConfig.DNSRecords, record)
+		if ttl, ok := config.RecordTTLs[record]; ok {
+			if zoneConfig.RecordTTLs == nil {
+				zoneConfig.RecordTTLs = map[string]int64{}
+			}
+			zoneConfig.RecordTTLs[record] = ttl
+		}
+	}
+
+	return configs, nil
+}
+
+// withHostedZone returns a copy of the config without records for the hosted zone
+func (c *Route53ZoneConfig) withHostedZone(zoneID string, zoneName *string) *Route53ZoneConfig {
+	zoneConfig := *c
+	zoneConfig.HostedZoneID = zoneID
+	zoneConfig.DNSRecords = nil
+	zoneConfig.RecordTTLs = nil
+	if c.SRV != nil {
+		srv := *c.SRV
+		zoneConfig.SRV = &srv
+	}
+	zoneConfig.setZoneName(zoneName)
+
+	return &zoneConfig
+}
+
+// setZoneName completes the parts of the config that depend on the name of the hosted zone
+func (c *Route53ZoneConfig) setZoneName(zoneName *string) {
+	if c.SRV != nil {
+		c.SRV.Name = fmt.Sprintf("_%s._%s.%s", c.SRV.Service, c.SRV.Protocol,
+			strings.TrimSuffix(aws.StringValue(zoneName), "."))
+	}
+}
+
+// findHostedZone returns the hosted zone with the longest name containing the record. Labels with
+// placeholders are skipped, since the zone has to be the same for every instance.
+func (l Route53ZoneConfigLoader) findHostedZone(record string,
+	isPublic bool,
+	vpcID *string,
+	cache map[string]*route53.HostedZone) (*route53.HostedZone, error) {
+	labels := strings.Split(strings.TrimSuffix(record, "."), ".")
+	for i := range labels {
+		name := strings.Join(labels[i:], ".")
+		if hasPlaceholders(name) {
+			continue
+		}
+
+		hostedZone, ok := cache[name]
+		if !ok {
+			var err error
+			hostedZone, err = l.findHostedZoneByName(name, isPublic, vpcID)
+			if err != nil {
+				return nil, err
+			}
+			cache[name] = hostedZone
+		}
+
+		if hostedZone != nil {
+			return hostedZone, nil
+		}
+	}
+
+	visibility := "private"
+	if isPublic {
+		visibility = "public"
+	}
+
+	return nil, fmt.Errorf("no %s hosted zone found for %s", visibility, record)
+}
+
+// findHostedZoneByName returns the hosted zone named exactly name with the requested visibility,
+// or nil if there is none. Private zones must be associated with vpcID if it is given.
+func (l Route53ZoneConfigLoader) findHostedZoneByName(name string, isPublic bool, vpcID *string) (*route53.HostedZone, error) {
+	input := &route53.ListHostedZonesByNameInput{
+		DNSName: aws.String(name),
+	}
+	normalizedName := normalizeRecordName(name)
+
+	for {
+		output, err := l.route53Client.ListHostedZonesByName(input)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, hostedZone := range output.HostedZones {
+			if normalizeRecordName(aws.StringValue(hostedZone.Name)) != normalizedName {
+				return nil, nil
+			}
+
+			isPrivate := hostedZone.Config != nil && aws.BoolValue(hostedZone.Config.PrivateZone)
+			if isPrivate == isPublic {
+				continue
+			}
+
+			if isPrivate && vpcID != nil {
+				associated, err := l.isAssociated(hostedZone.Id, *vpcID)
+				if err != nil {
+					return nil, err
+				}
+				if !associated {
+					continue
+				}
+			}
+
+			return hostedZone, nil
+		}
+
+		if !aws.BoolValue(output.IsTruncated) {
+			return nil, nil
+		}
+
+		input.DNSName = output.NextDNSName
+		input.HostedZoneId = output.NextHostedZoneId
+	}
+}
+
+// isAssociated returns true if the private hosted zone is associated with the VPC
+func (l Route53ZoneConfigLoader) isAssociated(zoneID *string, vpcID string) (bool, error) {
+	output, err := l.route53Client.GetHostedZone(&route53.GetHostedZoneInput{
+		Id: zoneID,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	for _, vpc := range output.VPCs {
+		if aws.StringValue(vpc.VPCId) == vpcID {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,172 @@
+package asgroute53
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/route53"
+)
+
+func Test_inferHostedZones(t *testing.T) {
+	hostedZones := []*route53.HostedZone{
+		{
+			Id:     aws.String("/hostedzone/PUBLIC"),
+			Name:   aws.String("example.com."),
+			Config: &route53.HostedZoneConfig{PrivateZone: aws.Bool(false)},
+		},
+		{
+			Id:     aws.String("/hostedzone/PRIVATE"),
+			Name:   aws.String("example.com."),
+			Config: &route53.HostedZoneConfig{PrivateZone: aws.Bool(true)},
+		},
+		{
+			Id:     aws.String("/hostedzone/OTHERVPC"),
+			Name:   aws.String("internal.example.com."),
+			Config: &route53.HostedZoneConfig{PrivateZone: aws.Bool(true)},
+		},
+		{
+			Id:     aws.String("/hostedzone/SUB"),
+			Name:   aws.String("sub.example.com."),
+			Config: &route53.HostedZoneConfig{PrivateZone: aws.Bool(true)},
+		},
+		{
+			Id:     aws.String("/hostedzone/NET"),
+			Name:   aws.String("example.net."),
+			Config: &route53.HostedZoneConfig{PrivateZone: aws.Bool(true)},
+		},
+	}
+	hostedZoneVPCs := map[string][]*route53.VPC{
+		"/hostedzone/PRIVATE":  {{VPCId: aws.String("vpc-1")}},
+		"/hostedzone/OTHERVPC": {{VPCId: aws.String("vpc-2")}},
+		"/hostedzone/SUB":      {{VPCId: aws.String("vpc-1")}},
+		"/hostedzone/NET":      {{VPCId: aws.String("vpc-2")}, {VPCId: aws.String("vpc-1")}},
+	}
+
+	tests := []struct {
+		name     string
+		records  string
+		isPublic bool
+		vpcID    *string
+		want     map[string][]string
+		wantErr  bool
+	}{
+		{
+			name:    "longest-match",
+			records: "a.sub.example.com,b.example.com",
+			vpcID:   aws.String("vpc-1"),
+			want: map[string][]string{
+				"SUB":     {"a.sub.example.com"},
+				"PRIVATE": {"b.example.com"},
+			},
+		},
+		{
+			name:    "several-zones",
+			records: "a.example.com,a.example.net,b.example.com",
+			vpcID:   aws.String("vpc-1"),
+			want: map[string][]string{
+				"PRIVATE": {"a.example.com", "b.example.com"},
+				"NET":     {"a.example.net"},
+			},
+		},
+		{
+			name:     "public",
+			records:  "a.sub.example.com",
+			isPublic: true,
+			want: map[string][]string{
+				"PUBLIC": {"a.sub.example.com"},
+			},
+		},
+		{
+			name:    "not-associated-with-vpc",
+			records: "a.internal.example.com",
+			vpcID:   aws.String("vpc-1"),
+			want: map[string][]string{
+				"PRIVATE": {"a.internal.example.com"},
+			},
+		},
+		{
+			name:    "placeholder-label",
+			records: "{instance-id}.example.com",
+			vpcID:   aws.String("vpc-1"),
+			want: map[string][]string{
+				"PRIVATE": {"{instance-id}.example.com"},
+			},
+		},
+		{
+			name:    "no-zone",
+			records: "a.example.org",
+			vpcID:   aws.String("vpc-1"),
+			wantErr: true,
+		},
+		{
+			name:     "no-public-zone",
+			records:  "a.example.net",
+			isPublic: true,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dnsRecordsKey := privateZoneTagKeys.dnsRecords
+			if tt.isPublic {
+				dnsRecordsKey = publicZoneTagKeys.dnsRecords
+			}
+			tags := []*ec2.Tag{
+				{Key: aws.String(dnsRecordsKey), Value: aws.String(tt.records)},
+			}
+			l := NewZoneConfigLoader(&mockedRoute53{
+				hostedZones:    hostedZones,
+				hostedZoneVPCs: hostedZoneVPCs,
+			})
+			configs, err := l.Load(&tags, tt.isPublic, tt.vpcID)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			got := map[string][]string{}
+			for _, config := range configs {
+				if _, ok := got[config.HostedZoneID]; ok {
+					t.Errorf("Load() returned %s twice", config.HostedZoneID)
+				}
+				got[config.HostedZoneID] = config.DNSRecords
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Load() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_inferHostedZones_perZoneOptions(t *testing.T) {
+	tags := []*ec2.Tag{
+		{Key: aws.String(privateZoneTagKeys.dnsRecords), Value: aws.String("{instance-id}.example.com,{instance-id}.example.net")},
+		{Key: aws.String(privateZoneTagKeys.ttl + ":{instance-id}.example.net"), Value: aws.String("30")},
+		{Key: aws.String(privateZoneTagKeys.srvService), Value: aws.String("http")},
+		{Key: aws.String(privateZoneTagKeys.srvProtocol), Value: aws.String("tcp")},
+		{Key: aws.String(privateZoneTagKeys.srvPort), Value: aws.String("80")},
+	}
+	l := NewZoneConfigLoader(&mockedRoute53{
+		hostedZones: []*route53.HostedZone{
+			{Id: aws.String("/hostedzone/COM"), Name: aws.String("example.com."), Config: &route53.HostedZoneConfig{PrivateZone: aws.Bool(true)}},
+			{Id: aws.String("/hostedzone/NET"), Name: aws.String("example.net."), Config: &route53.HostedZoneConfig{PrivateZone: aws.Bool(true)}},
+		},
+	})
+	configs, err := l.Load(&tags, false, nil)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(configs) != 2 {
+		t.Fatalf("Load() configs = %v, want 2", len(configs))
+	}
+	if configs[0].RecordTTLs != nil || configs[1].RecordTTLs["{instance-id}.example.net"] != 30 {
+		t.Errorf("Load() record TTLs = %v, %v", configs[0].RecordTTLs, configs[1].RecordTTLs)
+	}
+	if configs[0].SRV.Name != "_http._tcp.example.com" || configs[1].SRV.Name != "_http._tcp.example.net" {
+		t.Errorf("Load() SRV names = %v, %v", configs[0].SRV.Name, configs[1].SRV.Name)
+	}
+}
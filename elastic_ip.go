@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// elasticIPPoolKey tags both instances and the Elastic IPs of the pool they take addresses from
+const elasticIPPoolKey = "asg-route53-lambda:elastic-ip-pool"
+
+// findElasticIPPool returns the Elastic IP pool tagged on the instance, or an empty string
+func findElasticIPPool(instance *ec2.Instance) string {
+	for _, tag := range instance.Tags {
+		if aws.StringValue(tag.Key) == elasticIPPoolKey {
+			return aws.StringValue(tag.Value)
+		}
+	}
+
+	return ""
+}
+
+func (p *lifecycleProcessor) describePoolAddresses(pool string) ([]*ec2.Address, error) {
+	output, err := p.ec2Client.DescribeAddresses(&ec2.DescribeAddressesInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("tag:" + elasticIPPoolKey),
+				Values: []*string{aws.String(pool)},
+			},
+			{
+				Name:   aws.String("domain"),
+				Values: []*string{aws.String(ec2.DomainTypeVpc)},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return output.Addresses, nil
+}
+
+// associateElasticIP associates a free Elastic IP of the pool with the instance and sets it as the
+// public IP of the instance. Reassociation is disallowed, so an address taken by a concurrent
+// launch between listing and associating fails and the next free address is tried. An address
+// already associated with the instance is reused, so redelivered launches are idempotent.
+func (p *lifecycleProcessor) associateElasticIP(pool string, instance *ec2.Instance) error {
+	addresses, err := p.describePoolAddresses(pool)
+	if err != nil {
+		return err
+	}
+
+	for _, address := range addresses {
+		if aws.StringValue(address.InstanceId) == aws.StringValue(instance.InstanceId) {
+			fmt.Println("Elastic IP is already associated", *address.PublicIp)
+			instance.PublicIpAddress = address.PublicIp
+			return nil
+		}
+	}
+
+	for _, address := range addresses {
+		if address.AssociationId != nil {
+			continue
+		}
+
+		_, err := p.ec2Client.AssociateAddress(&ec2.AssociateAddressInput{
+			AllocationId:       address.AllocationId,
+			InstanceId:         instance.InstanceId,
+			AllowReassociation: aws.Bool(false),
+		})
+		if hasErrorCode(err, "Resource.AlreadyAssociated") {
+			fmt.Println("Elastic IP was taken concurrently, trying next", *address.PublicIp)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("Associated Elastic IP", *address.PublicIp)
+		instance.PublicIpAddress = address.PublicIp
+		return nil
+	}
+
+	return fmt.Errorf("no free Elastic IP in pool %s for instance %s", pool, aws.StringValue(instance.InstanceId))
+}
+
+// releaseElasticIP disassociates the Elastic IPs of the pool from the instance, returning them to
+// the pool
+func (p *lifecycleProcessor) releaseElasticIP(pool string, instanceID string) error {
+	addresses, err := p.describePoolAddresses(pool)
+	if err != nil {
+		return err
+	}
+
+	for _, address := range addresses {
+		if aws.StringValue(address.InstanceId) != instanceID || address.AssociationId == nil {
+			continue
+		}
+
+		_, err := p.ec2Client.DisassociateAddress(&ec2.DisassociateAddressInput{
+			AssociationId: address.AssociationId,
+		})
+		if hasErrorCode(err, "InvalidAssociationID.NotFound") {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("Returned Elastic IP to pool", pool, *address.PublicIp)
+	}
+
+	return nil
+}
+
+func hasErrorCode(err error, code string) bool {
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) {
+		return awsErr.Code() == code
+	}
+
+	return false
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+func newTestAddress(allocationID string, publicIP string, instanceID string) *ec2.Address {
+	address := &ec2.Address{
+		AllocationId: aws.String(allocationID),
+		PublicIp:     aws.String(publicIP),
+	}
+	if instanceID != "" {
+		address.InstanceId = aws.String(instanceID)
+		address.AssociationId = aws.String("eipassoc-" + allocationID)
+	}
+
+	return address
+}
+
+func Test_lifecycleProcessor_associateElasticIP(t *testing.T) {
+	tests := []struct {
+		name           string
+		m              *mockedEC2
+		wantAssociated []string
+		wantPublicIP   *string
+		wantErr        bool
+	}{
+		{
+			name: "free",
+			m: &mockedEC2{
+				addresses: []*ec2.Address{
+					newTestAddress("eipalloc-1", "203.0.113.1", "i-other"),
+					newTestAddress("eipalloc-2", "203.0.113.2", ""),
+				},
+			},
+			wantAssociated: []string{"eipalloc-2"},
+			wantPublicIP:   aws.String("203.0.113.2"),
+			wantErr:        false,
+		},
+		{
+			name: "taken-concurrently",
+			m: &mockedEC2{
+				addresses: []*ec2.Address{
+					newTestAddress("eipalloc-1", "203.0.113.1", ""),
+					newTestAddress("eipalloc-2", "203.0.113.2", ""),
+				},
+				associateAddressErrors: map[string]error{
+					"eipalloc-1": awserr.New("Resource.AlreadyAssociated", "already associated", nil),
+				},
+			},
+			wantAssociated: []string{"eipalloc-2"},
+			wantPublicIP:   aws.String("203.0.113.2"),
+			wantErr:        false,
+		},
+		{
+			name: "redelivered",
+			m: &mockedEC2{
+				addresses: []*ec2.Address{
+					newTestAddress("eipalloc-1", "203.0.113.1", ""),
+					newTestAddress("eipalloc-2", "203.0.113.2", "i-1"),
+				},
+			},
+			wantAssociated: nil,
+			wantPublicIP:   aws.String("203.0.113.2"),
+			wantErr:        false,
+		},
+		{
+			name:           "empty-pool",
+			m:              &mockedEC2{},
+			wantAssociated: nil,
+			wantErr:        true,
+		},
+		{
+			name: "all-taken",
+			m: &mockedEC2{
+				addresses: []*ec2.Address{
+					newTestAddress("eipalloc-1", "203.0.113.1", ""),
+				},
+				associateAddressErrors: map[string]error{
+					"eipalloc-1": awserr.New("Resource.AlreadyAssociated", "already associated", nil),
+				},
+			},
+			wantAssociated: nil,
+			wantErr:        true,
+		},
+		{
+			name: "associate-error",
+			m: &mockedEC2{
+				addresses: []*ec2.Address{
+					newTestAddress("eipalloc-1", "203.0.113.1", ""),
+					newTestAddress("eipalloc-2", "203.0.113.2", ""),
+				},
+				associateAddressErrors: map[string]error{
+					"eipalloc-1": errors.New("associateError"),
+				},
+			},
+			wantAssociated: nil,
+			wantErr:        true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestProcessor(tt.m, &mockedAutoScaling{}, newTestSettings(failurePolicyAbandon))
+			instance := &ec2.Instance{InstanceId: aws.String("i-1")}
+
+			err := p.associateElasticIP("pool", instance)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("lifecycleProcessor.associateElasticIP() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(tt.m.associatedAllocationIDs, tt.wantAssociated) {
+				t.Errorf("lifecycleProcessor.associateElasticIP() associated = %v, want %v", tt.m.associatedAllocationIDs, tt.wantAssociated)
+			}
+			if aws.StringValue(instance.PublicIpAddress) != aws.StringValue(tt.wantPublicIP) {
+				t.Errorf("lifecycleProcessor.associateElasticIP() public IP = %v, want %v",
+					aws.StringValue(instance.PublicIpAddress), aws.StringValue(tt.wantPublicIP))
+			}
+		})
+	}
+}
+
+func Test_lifecycleProcessor_releaseElasticIP(t *testing.T) {
+	addresses := []*ec2.Address{
+		newTestAddress("eipalloc-1", "203.0.113.1", "i-other"),
+		newTestAddress("eipalloc-2", "203.0.113.2", "i-1"),
+		newTestAddress("eipalloc-3", "203.0.113.3", ""),
+	}
+	tests := []struct {
+		name              string
+		m                 *mockedEC2
+		wantDisassociated []string
+		wantErr           bool
+	}{
+		{
+			name:              "associated",
+			m:                 &mockedEC2{addresses: addresses},
+			wantDisassociated: []string{"eipassoc-eipalloc-2"},
+			wantErr:           false,
+		},
+		{
+			name: "already-disassociated",
+			m: &mockedEC2{
+				addresses:                addresses,
+				disassociateAddressError: awserr.New("InvalidAssociationID.NotFound", "association not found", nil),
+			},
+			wantDisassociated: nil,
+			wantErr:           false,
+		},
+		{
+			name: "disassociate-error",
+			m: &mockedEC2{
+				addresses:                addresses,
+				disassociateAddressError: errors.New("disassociateError"),
+			},
+			wantDisassociated: nil,
+			wantErr:           true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestProcessor(tt.m, &mockedAutoScaling{}, newTestSettings(failurePolicyAbandon))
+
+			err := p.releaseElasticIP("pool", "i-1")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("lifecycleProcessor.releaseElasticIP() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(tt.m.disassociatedAssociationIDs, tt.wantDisassociated) {
+				t.Errorf("lifecycleProcessor.releaseElasticIP() disassociated = %v, want %v", tt.m.disassociatedAssociationIDs, tt.wantDisassociated)
+			}
+		})
+	}
+}
+
+func Test_lifecycleProcessor_addChanges_elasticIPPool(t *testing.T) {
+	tags := []*ec2.Tag{
+		{Key: aws.String(elasticIPPoolKey), Value: aws.String("pool")},
+		{Key: aws.String("asg-route53-lambda:public-hosted-zone-id"), Value: aws.String("ZONE")},
+		{Key: aws.String("asg-route53-lambda:public-dns-records"), Value: aws.String("eip.example.com")},
+		{Key: aws.String("asg-route53-lambda:public-address-source"), Value: aws.String("elastic-ip")},
+	}
+	newInstance := func(association *ec2.InstanceNetworkInterfaceAssociation) *ec2.Instance {
+		instance := &ec2.Instance{
+			InstanceId:       aws.String("i-1"),
+			PrivateIpAddress: aws.String("10.0.0.1"),
+			Tags:             tags,
+			NetworkInterfaces: []*ec2.InstanceNetworkInterface{
+				{
+					PrivateIpAddresses: []*ec2.InstancePrivateIpAddress{
+						{
+							Primary:          aws.Bool(true),
+							PrivateIpAddress: aws.String("10.0.0.1"),
+							Association:      association,
+						},
+					},
+				},
+			},
+		}
+		if association != nil {
+			instance.PublicIpAddress = association.PublicIp
+		}
+		return instance
+	}
+	// associateElasticIP patches the described instance, so each state is a new instance
+	before := func() *ec2.Instance {
+		return newInstance(&ec2.InstanceNetworkInterfaceAssociation{
+			IpOwnerId: aws.String("amazon"),
+			PublicIp:  aws.String("198.51.100.1"),
+		})
+	}
+	after := newInstance(&ec2.InstanceNetworkInterfaceAssociation{
+		IpOwnerId: aws.String("123456789012"),
+		PublicIp:  aws.String("203.0.113.2"),
+	})
+	tests := []struct {
+		name      string
+		instances []*ec2.Instance
+	}{
+		{
+			name:      "visible",
+			instances: []*ec2.Instance{before(), after},
+		},
+		{
+			name:      "delayed",
+			instances: []*ec2.Instance{before(), before(), after},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ec2Client := &mockedEC2{
+				instances: map[string][]*ec2.Instance{"i-1": tt.instances},
+				addresses: []*ec2.Address{newTestAddress("eipalloc-2", "203.0.113.2", "")},
+			}
+			route53Client := &mockedRoute53{hostedZoneNames: map[string]string{"ZONE": "example.com."}}
+			p := withRoute53(newTestProcessor(ec2Client, &mockedAutoScaling{}, newTestSettings(failurePolicyAbandon)), route53Client)
+			batch := p.asgRoute53.NewChangeBatch()
+
+			err := p.addChanges(context.Background(), batch, &lifecycleMessage{id: "m1", event: newTestEvent("i-1", launching)})
+			if err != nil {
+				t.Fatalf("lifecycleProcessor.addChanges() error = %v", err)
+			}
+			if _, errs := batch.Submit(); errs["m1"] != nil {
+				t.Fatalf("ChangeBatch.Submit() error = %v", errs["m1"])
+			}
+
+			got := route53Client.changedValues("A")["eip.example.com"]
+			if !reflect.DeepEqual(got, []string{"203.0.113.2"}) {
+				t.Errorf("lifecycleProcessor.addChanges() A records = %v, want [203.0.113.2]", got)
+			}
+		})
+	}
+}
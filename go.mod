@@ -3,7 +3,7 @@ module github.com/vroad/asg-route53
 go 1.15
 
 require (
-	github.com/aws/aws-lambda-go v1.19.1
+	github.com/aws/aws-lambda-go v1.28.0
 	github.com/aws/aws-sdk-go v1.34.32
 	golang.org/x/net v0.0.0-20200925080053-05aa5d4ee321 // indirect
 )
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/vroad/asg-route53/asgroute53"
+)
+
+// describeGroupTags returns the tags of the Auto Scaling group, including tags which are not
+// propagated at launch. Tags are described once per group and invocation. Functions without
+// permission to describe groups get no group tags.
+func (p *lifecycleProcessor) describeGroupTags(groupName string) ([]*ec2.Tag, error) {
+	if tags, ok := p.groupTags[groupName]; ok {
+		return tags, nil
+	}
+
+	output, err := p.asgClient.DescribeAutoScalingGroups(&autoscaling.DescribeAutoScalingGroupsInput{
+		AutoScalingGroupNames: []*string{aws.String(groupName)},
+	})
+	if hasErrorCode(err, "AccessDenied") {
+		fmt.Println("Not allowed to describe auto scaling groups, ignoring group tags: ", err)
+		output, err = &autoscaling.DescribeAutoScalingGroupsOutput{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed describing auto scaling group %s: %w", groupName, err)
+	}
+
+	tags := []*ec2.Tag{
+		{Key: aws.String(asgroute53.ASGNameTagKey), Value: aws.String(groupName)},
+	}
+	// The group may already be deleted when its last instances terminate
+	if len(output.AutoScalingGroups) > 0 {
+		for _, tag := range output.AutoScalingGroups[0].Tags {
+			tags = append(tags, &ec2.Tag{Key: tag.Key, Value: tag.Value})
+		}
+	}
+
+	p.groupTags[groupName] = tags
+	return tags, nil
+}
+
+// mergeGroupTags adds the tags of the Auto Scaling group that are missing on the instance
+func (p *lifecycleProcessor) mergeGroupTags(groupName string, instance *ec2.Instance) error {
+	groupTags, err := p.describeGroupTags(groupName)
+	if err != nil {
+		return err
+	}
+
+	instance.Tags = asgroute53.MergeTags(groupTags, instance.Tags)
+	return nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/vroad/asg-route53/asgroute53"
+)
+
+func Test_lifecycleProcessor_describeGroupTags(t *testing.T) {
+	groupNameTag := &ec2.Tag{Key: aws.String(asgroute53.ASGNameTagKey), Value: aws.String("asg")}
+	tests := []struct {
+		name      string
+		m         *mockedAutoScaling
+		want      []*ec2.Tag
+		wantCalls int
+		wantErr   bool
+	}{
+		{
+			name: "tagged",
+			m: &mockedAutoScaling{
+				groupTags: []*autoscaling.TagDescription{
+					{Key: aws.String("role"), Value: aws.String("web")},
+				},
+			},
+			want: []*ec2.Tag{
+				groupNameTag,
+				{Key: aws.String("role"), Value: aws.String("web")},
+			},
+			wantCalls: 1,
+			wantErr:   false,
+		},
+		{
+			name:      "deleted",
+			m:         &mockedAutoScaling{groupDeleted: true},
+			want:      []*ec2.Tag{groupNameTag},
+			wantCalls: 1,
+			wantErr:   false,
+		},
+		{
+			name: "access-denied",
+			m: &mockedAutoScaling{
+				describeAutoScalingGroupsError: awserr.New("AccessDenied", "not authorized", nil),
+			},
+			want:      []*ec2.Tag{groupNameTag},
+			wantCalls: 1,
+			wantErr:   false,
+		},
+		{
+			name: "describe-error",
+			m: &mockedAutoScaling{
+				describeAutoScalingGroupsError: errors.New("describeError"),
+			},
+			want:      nil,
+			wantCalls: 2,
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestProcessor(&mockedEC2{}, tt.m, newTestSettings(failurePolicyAbandon))
+
+			// Tags are described once per invocation, unless describing them failed
+			for i := 0; i < 2; i++ {
+				got, err := p.describeGroupTags("asg")
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("lifecycleProcessor.describeGroupTags() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("lifecycleProcessor.describeGroupTags() = %v, want %v", got, tt.want)
+				}
+			}
+			if tt.m.describeAutoScalingGroupsCalls != tt.wantCalls {
+				t.Errorf("lifecycleProcessor.describeGroupTags() calls = %v, want %v", tt.m.describeAutoScalingGroupsCalls, tt.wantCalls)
+			}
+		})
+	}
+}
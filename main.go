@@ -5,11 +5,14 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/autoscaling"
 	"github.com/aws/aws-sdk-go/service/autoscaling/autoscalingiface"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
 	"github.com/aws/aws-sdk-go/service/route53"
 	"github.com/vroad/asg-route53/asgroute53"
 
@@ -18,6 +21,15 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 )
 
+const (
+	launchLifecycleActionDetailType    = "EC2 Instance-launch Lifecycle Action"
+	terminateLifecycleActionDetailType = "EC2 Instance-terminate Lifecycle Action"
+	testNotificationEvent              = "autoscaling:TEST_NOTIFICATION"
+)
+
+// publicIPPollInterval is how often instances are described while waiting for a public IP
+const publicIPPollInterval = 5 * time.Second
+
 type (
 	asgLifecycleEventDetail struct {
 		LifecycleActionToken string
@@ -25,9 +37,55 @@ type (
 		LifecycleHookName    string
 		EC2InstanceID        string
 		LifecycleTransition  string
+		Event                string
+		// NotificationMetadata of the lifecycle hook, which may hold a JSON zone config
+		NotificationMetadata string
+	}
+	// lifecycleMessage is a lifecycle notification identified by the ID of the message carrying it
+	lifecycleMessage struct {
+		id            string
+		event         *asgLifecycleEventDetail
+		parseErr      error
+		failurePolicy failurePolicy
+		// elasticIPPool is the Elastic IP pool tagged on the instance
+		elasticIPPool string
+	}
+	// lifecycleProcessor holds the clients shared by all lifecycle events of an invocation
+	lifecycleProcessor struct {
+		ec2Client        ec2iface.EC2API
+		asgClient        autoscalingiface.AutoScalingAPI
+		asgRoute53       *asgroute53.ASGRoute53
+		zoneConfigLoader *asgroute53.Route53ZoneConfigLoader
+		settings         *settings
+		// groupTags caches the tags of Auto Scaling groups by name
+		groupTags map[string][]*ec2.Tag
+		// pollInterval is how often instances are described while waiting for a public IP
+		pollInterval time.Duration
+		// heartbeatInterval is how often lifecycle action heartbeats are recorded while waiting
+		heartbeatInterval time.Duration
 	}
 )
 
+// newProcessor creates the lifecycle processor of an invocation. Tests replace it to use mocked clients.
+var newProcessor = func(ctx context.Context, settings *settings) *lifecycleProcessor {
+	return newLifecycleProcessor(ctx, session.Must(session.NewSession()), settings)
+}
+
+func newLifecycleProcessor(ctx context.Context, session *session.Session, settings *settings) *lifecycleProcessor {
+	// Route 53 calls are retried by the rate limited client instead of the SDK
+	route53Client := asgroute53.NewRetryingClient(ctx, route53.New(session, aws.NewConfig().WithMaxRetries(0)))
+	return &lifecycleProcessor{
+		ec2Client:         ec2.New(session),
+		asgClient:         autoscaling.New(session),
+		asgRoute53:        asgroute53.New(route53Client),
+		zoneConfigLoader:  asgroute53.NewZoneConfigLoader(route53Client),
+		settings:          settings,
+		groupTags:         map[string][]*ec2.Tag{},
+		pollInterval:      publicIPPollInterval,
+		heartbeatInterval: asgroute53.HeartbeatInterval,
+	}
+}
+
 func completeLifecycleAction(asgClient autoscalingiface.AutoScalingAPI, event *asgLifecycleEventDetail, result string) error {
 	if _, err := asgClient.CompleteLifecycleAction(&autoscaling.CompleteLifecycleActionInput{
 		InstanceId:            &event.EC2InstanceID,
@@ -36,8 +94,8 @@ func completeLifecycleAction(asgClient autoscalingiface.AutoScalingAPI, event *a
 		AutoScalingGroupName:  &event.AutoScalingGroupName,
 		LifecycleActionResult: aws.String(result),
 	}); err != nil {
-		fmt.Println("Failed completing lifecycle action: ", result)
-		return err
+		fmt.Println("Failed completing lifecycle action: ", result, err)
+		return fmt.Errorf("failed completing lifecycle action of %s with %s: %w", event.EC2InstanceID, result, err)
 	}
 
 	fmt.Println("Completed lifecycle action: ", result)
@@ -45,60 +103,122 @@ func completeLifecycleAction(asgClient autoscalingiface.AutoScalingAPI, event *a
 	return nil
 }
 
+func recordLifecycleActionHeartbeat(asgClient autoscalingiface.AutoScalingAPI, event *asgLifecycleEventDetail) error {
+	if _, err := asgClient.RecordLifecycleActionHeartbeat(&autoscaling.RecordLifecycleActionHeartbeatInput{
+		InstanceId:           &event.EC2InstanceID,
+		LifecycleHookName:    &event.LifecycleHookName,
+		LifecycleActionToken: &event.LifecycleActionToken,
+		AutoScalingGroupName: &event.AutoScalingGroupName,
+	}); err != nil {
+		fmt.Println("Failed recording lifecycle action heartbeat: ", event.EC2InstanceID)
+		return err
+	}
+
+	fmt.Println("Recorded lifecycle action heartbeat: ", event.EC2InstanceID)
+
+	return nil
+}
+
 func appendZoneConfig(zoneConfigLoader *asgroute53.Route53ZoneConfigLoader,
 	zoneConfigs []*asgroute53.Route53ZoneConfig,
 	tags *[]*ec2.Tag,
+	vpcID *string,
 	isPublic bool) ([]*asgroute53.Route53ZoneConfig, error) {
-	zoneConfig, err := zoneConfigLoader.Load(tags, isPublic)
+	loaded, err := zoneConfigLoader.LoadAll(tags, isPublic, vpcID)
 	if err != nil {
 		return nil, err
 	}
 
-	if zoneConfig == nil {
-		return zoneConfigs, nil
-	}
-
-	return append(zoneConfigs, zoneConfig), nil
+	return append(zoneConfigs, loaded...), nil
 }
 
-func lifecycleEventHandler(session *session.Session, event *asgLifecycleEventDetail) error {
-	route53Client := route53.New(session)
-	ec2Client := ec2.New(session)
-	asgRoute53 := asgroute53.New(route53Client)
-	zoneConfigLoader := asgroute53.NewZoneConfigLoader(route53Client)
+func isSupportedTransition(event *asgLifecycleEventDetail) bool {
+	return event.LifecycleTransition == "autoscaling:EC2_INSTANCE_LAUNCHING" ||
+		event.LifecycleTransition == "autoscaling:EC2_INSTANCE_TERMINATING"
+}
 
-	describeInstancesResp, err := ec2Client.DescribeInstances(&ec2.DescribeInstancesInput{
-		InstanceIds: []*string{
-			aws.String(event.EC2InstanceID),
-		},
-	})
+// addChanges adds record set changes for the lifecycle event to the batch under the message ID
+func (p *lifecycleProcessor) addChanges(ctx context.Context, batch *asgroute53.ChangeBatch, message *lifecycleMessage) error {
+	key, event := message.id, message.event
+	instance, err := p.describeInstance(event.EC2InstanceID)
 	if err != nil {
 		return err
 	}
-
-	if len(describeInstancesResp.Reservations) < 0 || len(describeInstancesResp.Reservations[0].Instances) < 0 {
-		return errors.New("failed to find an EC2 instance")
+	if err := p.mergeGroupTags(event.AutoScalingGroupName, instance); err != nil {
+		return err
 	}
 
-	instance := describeInstancesResp.Reservations[0].Instances[0]
-	zoneConfigs := []*asgroute53.Route53ZoneConfig{}
-
-	zoneConfigs, err = appendZoneConfig(zoneConfigLoader, zoneConfigs, &instance.Tags, false)
+	message.failurePolicy, err = p.settings.failurePolicyFor(event.LifecycleTransition, instance.Tags)
 	if err != nil {
 		return err
 	}
-	zoneConfigs, err = appendZoneConfig(zoneConfigLoader, zoneConfigs, &instance.Tags, true)
+
+	// The metadata is validated completely before any zone is looked up in Route 53
+	metadata, err := p.zoneConfigLoader.ParseMetadata(event.NotificationMetadata)
 	if err != nil {
 		return err
 	}
+
+	zoneConfigs := []*asgroute53.Route53ZoneConfig{}
+
+	if metadata != nil {
+		zoneConfigs, err = p.zoneConfigLoader.LoadMetadata(metadata, instance.VpcId)
+		if err != nil {
+			return err
+		}
+	}
+	if metadata == nil || metadata.Mode == asgroute53.MetadataModeMerge {
+		zoneConfigs, err = appendZoneConfig(p.zoneConfigLoader, zoneConfigs, &instance.Tags, instance.VpcId, false)
+		if err != nil {
+			return err
+		}
+		zoneConfigs, err = appendZoneConfig(p.zoneConfigLoader, zoneConfigs, &instance.Tags, instance.VpcId, true)
+		if err != nil {
+			return err
+		}
+	}
 	zoneConfigsJSON, _ := json.Marshal(zoneConfigs)
 	fmt.Println("zoneConfigs", string(zoneConfigsJSON))
 
+	message.elasticIPPool = findElasticIPPool(instance)
+	if event.LifecycleTransition == "autoscaling:EC2_INSTANCE_LAUNCHING" && message.elasticIPPool != "" {
+		if err := p.associateElasticIP(message.elasticIPPool, instance); err != nil {
+			return err
+		}
+
+		// The addresses of the network interfaces and the public DNS name change with the association
+		instance, err = p.waitForPublicIP(ctx, event, aws.StringValue(instance.PublicIpAddress))
+		if err != nil {
+			return err
+		}
+		if err := p.mergeGroupTags(event.AutoScalingGroupName, instance); err != nil {
+			return err
+		}
+	}
+
+	if event.LifecycleTransition == "autoscaling:EC2_INSTANCE_LAUNCHING" && instance.PublicIpAddress == nil && needsPublicIPv4(zoneConfigs) {
+		instance, err = p.waitForPublicIP(ctx, event, "")
+		if err != nil {
+			return err
+		}
+		if err := p.mergeGroupTags(event.AutoScalingGroupName, instance); err != nil {
+			return err
+		}
+	}
+
+	// Terminating instances need their interfaces too, to find the PTR records of their addresses
+	if err := p.resolveNetworkInterfaces(zoneConfigs, instance); err != nil {
+		return err
+	}
+
 	switch event.LifecycleTransition {
 	case "autoscaling:EC2_INSTANCE_LAUNCHING":
+		if err := asgroute53.CheckCNAMEConflicts(zoneConfigs, instance); err != nil {
+			return err
+		}
 		fmt.Println("Running upsert")
 		for _, zoneConfig := range zoneConfigs {
-			err := asgRoute53.UpsertRecordSets(zoneConfig, instance)
+			err := batch.UpsertRecordSets(key, zoneConfig, instance)
 			if err != nil {
 				return err
 			}
@@ -106,7 +226,7 @@ func lifecycleEventHandler(session *session.Session, event *asgLifecycleEventDet
 	case "autoscaling:EC2_INSTANCE_TERMINATING":
 		fmt.Println("Running delete")
 		for _, zoneConfig := range zoneConfigs {
-			err := asgRoute53.DeleteRecordSets(zoneConfig, instance)
+			err := batch.DeleteRecordSets(key, zoneConfig, instance)
 			if err != nil {
 				return err
 			}
@@ -118,36 +238,468 @@ func lifecycleEventHandler(session *session.Session, event *asgLifecycleEventDet
 	return nil
 }
 
-// Handler for Lambda
-func Handler(ctx context.Context, snsEvent *events.SNSEvent) error {
-	SNSMessage := snsEvent.Records[0].SNS.Message
-	fmt.Println("SNS Message", SNSMessage)
+func (p *lifecycleProcessor) describeInstance(instanceID string) (*ec2.Instance, error) {
+	describeInstancesResp, err := p.ec2Client.DescribeInstances(&ec2.DescribeInstancesInput{
+		InstanceIds: []*string{
+			aws.String(instanceID),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(describeInstancesResp.Reservations) == 0 || len(describeInstancesResp.Reservations[0].Instances) == 0 {
+		return nil, errors.New("failed to find an EC2 instance")
+	}
+
+	return describeInstancesResp.Reservations[0].Instances[0], nil
+}
+
+// resolveNetworkInterfaces resolves the network interfaces selected by tag
+func (p *lifecycleProcessor) resolveNetworkInterfaces(zoneConfigs []*asgroute53.Route53ZoneConfig, instance *ec2.Instance) error {
+	var networkInterfaces []*ec2.NetworkInterface
+	for _, zoneConfig := range zoneConfigs {
+		if zoneConfig.NetworkInterfaceTag == nil {
+			continue
+		}
+
+		if networkInterfaces == nil {
+			output, err := p.ec2Client.DescribeNetworkInterfaces(&ec2.DescribeNetworkInterfacesInput{
+				Filters: []*ec2.Filter{
+					{
+						Name:   aws.String("attachment.instance-id"),
+						Values: []*string{instance.InstanceId},
+					},
+				},
+			})
+			if err != nil {
+				return err
+			}
+			networkInterfaces = output.NetworkInterfaces
+		}
+
+		if err := zoneConfig.ResolveNetworkInterface(networkInterfaces); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func needsPublicIPv4(zoneConfigs []*asgroute53.Route53ZoneConfig) bool {
+	for _, zoneConfig := range zoneConfigs {
+		if zoneConfig.NeedsPublicIPv4() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// waitForPublicIP polls the instance until it has the public IPv4 address, or any if publicIP is empty
+func (p *lifecycleProcessor) waitForPublicIP(ctx context.Context, event *asgLifecycleEventDetail, publicIP string) (*ec2.Instance, error) {
+	start := time.Now()
+	end := start.Add(p.settings.publicIPTimeout)
+	if deadline, ok := ctx.Deadline(); ok && deadline.Add(-asgroute53.DeadlineMargin).Before(end) {
+		end = deadline.Add(-asgroute53.DeadlineMargin)
+	}
+	lastHeartbeat := start
+
+	fmt.Println("Waiting for public IPv4 address of", event.EC2InstanceID)
+	for {
+		instance, err := p.describeInstance(event.EC2InstanceID)
+		if err != nil {
+			return nil, err
+		}
+		if instance.PublicIpAddress != nil && (publicIP == "" || *instance.PublicIpAddress == publicIP) {
+			fmt.Println("Found public IPv4 address", *instance.PublicIpAddress)
+			return instance, nil
+		}
+
+		remaining := time.Until(end)
+		if remaining <= 0 {
+			return nil, fmt.Errorf("instance %s has no public IPv4 address after waiting %s, public zone records cannot be created",
+				event.EC2InstanceID, time.Since(start).Round(time.Second))
+		}
+		if remaining > p.pollInterval {
+			remaining = p.pollInterval
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(remaining):
+		}
+
+		if time.Since(lastHeartbeat) >= p.heartbeatInterval {
+			if err := recordLifecycleActionHeartbeat(p.asgClient, event); err != nil {
+				return nil, err
+			}
+			lastHeartbeat = time.Now()
+		}
+	}
+}
+
+// waitForChanges waits until the changes of successfully processed messages are INSYNC
+func (p *lifecycleProcessor) waitForChanges(ctx context.Context, messages []*lifecycleMessage, changeIDs map[string][]string, errs map[string]error) {
+	var ids []string
+	var waiting []*lifecycleMessage
+	for _, message := range messages {
+		if errs[message.id] != nil {
+			continue
+		}
+		waiting = append(waiting, message)
+		for _, changeID := range changeIDs[message.id] {
+			if !containsString(ids, changeID) {
+				ids = append(ids, changeID)
+			}
+		}
+	}
+
+	if len(ids) == 0 {
+		return
+	}
+
+	fmt.Println("Waiting for changes to become INSYNC", ids)
+	// The records are applied, so failed heartbeats do not fail the messages
+	if err := p.asgRoute53.WaitForChanges(ctx, ids, p.heartbeat(waiting, map[string]error{})); err != nil {
+		fmt.Println("Stopped waiting for changes, continuing: ", err)
+	}
+}
+
+// drain holds terminating lifecycle actions for the largest TTL of their records plus the margin
+func (p *lifecycleProcessor) drain(ctx context.Context, messages []*lifecycleMessage, batch *asgroute53.ChangeBatch, errs map[string]error) error {
+	var duration time.Duration
+	for _, message := range messages {
+		if d := batch.MaxTTL(message.id) + *p.settings.terminationDrainMargin; d > duration {
+			duration = d
+		}
+	}
+
+	fmt.Println("Draining terminating instances for", duration)
+	return sleepWithHeartbeats(ctx, duration, p.heartbeatInterval, p.heartbeat(messages, errs))
+}
+
+// heartbeat returns a function recording heartbeats, dropping messages whose heartbeat fails
+func (p *lifecycleProcessor) heartbeat(messages []*lifecycleMessage, errs map[string]error) func() error {
+	return func() error {
+		var beating []*lifecycleMessage
+		for _, message := range messages {
+			if err := recordLifecycleActionHeartbeat(p.asgClient, message.event); err != nil {
+				fmt.Println("Stopped recording heartbeats for", message.event.EC2InstanceID, err)
+				errs[message.id] = fmt.Errorf("failed recording lifecycle action heartbeat of %s: %w", message.event.EC2InstanceID, err)
+				continue
+			}
+			beating = append(beating, message)
+		}
+		messages = beating
+		return nil
+	}
+}
+
+// sleepWithHeartbeats waits for the duration, calling heartbeat at the interval
+func sleepWithHeartbeats(ctx context.Context, duration time.Duration, interval time.Duration, heartbeat func() error) error {
+	end := time.Now().Add(duration)
+	var err error
+	if deadline, ok := ctx.Deadline(); ok && deadline.Add(-asgroute53.DeadlineMargin).Before(end) {
+		end = deadline.Add(-asgroute53.DeadlineMargin)
+		err = fmt.Errorf("invocation deadline allows waiting only until %s", end.Format(time.RFC3339))
+	}
+
+	for {
+		remaining := time.Until(end)
+		if remaining <= 0 {
+			return err
+		}
+		if remaining > interval {
+			remaining = interval
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(remaining):
+		}
+
+		if time.Until(end) > 0 {
+			if hbErr := heartbeat(); hbErr != nil {
+				return hbErr
+			}
+		}
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *lifecycleProcessor) completeMessage(message *lifecycleMessage, errs map[string]error) {
+	if err := p.complete(message, errs[message.id]); err != nil {
+		fmt.Println("Failed processing message", message.id, err)
+		errs[message.id] = err
+		return
+	}
+
+	delete(errs, message.id)
+}
+
+// complete completes the lifecycle action of the message, applying its failure policy on error
+func (p *lifecycleProcessor) complete(message *lifecycleMessage, err error) error {
+	if err == nil && message.elasticIPPool != "" && message.event.LifecycleTransition == "autoscaling:EC2_INSTANCE_TERMINATING" {
+		err = p.releaseElasticIP(message.elasticIPPool, message.event.EC2InstanceID)
+	}
+
+	if err == nil {
+		return completeLifecycleAction(p.asgClient, message.event, "CONTINUE")
+	}
+
+	if message.failurePolicy == failurePolicyRetry {
+		fmt.Println("Leaving lifecycle action open for redelivery", message.event.EC2InstanceID)
+		return err
+	}
+
+	if completeErr := completeLifecycleAction(p.asgClient, message.event, string(message.failurePolicy)); completeErr != nil {
+		return fmt.Errorf("%w; %s", err, completeErr)
+	}
+
+	fmt.Println("Failed updating records, lifecycle action completed with", message.failurePolicy, err)
+
+	return nil
+}
+
+// parseLifecycleMessage decodes a lifecycle notification, unwrapping SNS and EventBridge envelopes
+func parseLifecycleMessage(message string) (*asgLifecycleEventDetail, error) {
+	var envelope struct {
+		Type       string
+		Message    string
+		DetailType string          `json:"detail-type"`
+		Detail     json.RawMessage `json:"detail"`
+	}
+	if err := json.Unmarshal([]byte(message), &envelope); err != nil {
+		return nil, err
+	}
+	switch {
+	case envelope.Type == "Notification" && envelope.Message != "":
+		message = envelope.Message
+	case envelope.DetailType != "" && len(envelope.Detail) > 0:
+		message = string(envelope.Detail)
+	}
 
 	var event asgLifecycleEventDetail
-	err := json.Unmarshal([]byte(SNSMessage), &event)
+	if err := json.Unmarshal([]byte(message), &event); err != nil {
+		return nil, err
+	}
+
+	return &event, nil
+}
+
+// Handler for Lambda
+func Handler(ctx context.Context, payload json.RawMessage) (*events.SQSEventResponse, error) {
+	var envelope struct {
+		DetailType string `json:"detail-type"`
+		Records    []struct {
+			EventSource string
+		}
+	}
+	if err := json.Unmarshal(payload, &envelope); err != nil {
+		return nil, err
+	}
+
+	settings, err := loadSettings()
 	if err != nil {
+		return nil, err
+	}
+
+	if envelope.DetailType != "" {
+		return nil, handleCloudWatchEvent(ctx, settings, payload)
+	}
+
+	if len(envelope.Records) > 0 && envelope.Records[0].EventSource == "aws:sqs" {
+		return handleSQSEvent(ctx, settings, payload)
+	}
+
+	return nil, handleSNSEvent(ctx, settings, payload)
+}
+
+// handleSNSEvent processes all lifecycle messages of the SNS event and reports failed messages
+func handleSNSEvent(ctx context.Context, settings *settings, payload json.RawMessage) error {
+	var snsEvent events.SNSEvent
+	if err := json.Unmarshal(payload, &snsEvent); err != nil {
 		return err
 	}
 
-	if event.LifecycleTransition != "autoscaling:EC2_INSTANCE_LAUNCHING" && event.LifecycleTransition != "autoscaling:EC2_INSTANCE_TERMINATING" {
-		fmt.Println("The event does not contain supported LifecycleTransition, exiting.")
+	if len(snsEvent.Records) == 0 {
+		fmt.Println("The event does not contain any records, exiting.")
+		return nil
+	}
+
+	var messages []*lifecycleMessage
+	for _, record := range snsEvent.Records {
+		fmt.Println("SNS Message", record.SNS.MessageID, record.SNS.Message)
+		messages = append(messages, newLifecycleMessage(record.SNS.MessageID, record.SNS.Message))
+	}
+
+	errs := processLifecycleMessages(ctx, settings, messages)
+	if len(errs) == 0 {
 		return nil
 	}
 
-	session := session.Must(session.NewSession())
-	asgClient := autoscaling.New(session)
-	err = lifecycleEventHandler(session, &event)
-	if err != nil && event.LifecycleTransition == "autoscaling:EC2_INSTANCE_LAUNCHING" {
-		completeLifecycleAction(asgClient, &event, "ABANDON")
+	var failures []string
+	for _, message := range messages {
+		if err := errs[message.id]; err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %s", message.id, err))
+		}
+	}
+
+	return fmt.Errorf("failed processing %d of %d messages: %s", len(failures), len(messages), strings.Join(failures, "; "))
+}
+
+func handleCloudWatchEvent(ctx context.Context, settings *settings, payload json.RawMessage) error {
+	var cloudWatchEvent events.CloudWatchEvent
+	if err := json.Unmarshal(payload, &cloudWatchEvent); err != nil {
 		return err
 	}
 
-	err = completeLifecycleAction(asgClient, &event, "CONTINUE")
-	if err != nil {
+	fmt.Println("EventBridge event", cloudWatchEvent.DetailType, string(cloudWatchEvent.Detail))
+
+	if cloudWatchEvent.DetailType != launchLifecycleActionDetailType && cloudWatchEvent.DetailType != terminateLifecycleActionDetailType {
+		fmt.Println("The event is not a lifecycle action, exiting.")
+		return nil
+	}
+
+	var event asgLifecycleEventDetail
+	if err := json.Unmarshal(cloudWatchEvent.Detail, &event); err != nil {
 		return err
 	}
 
-	return nil
+	errs := processLifecycleMessages(ctx, settings, []*lifecycleMessage{
+		{
+			id:    cloudWatchEvent.ID,
+			event: &event,
+		},
+	})
+
+	return errs[cloudWatchEvent.ID]
+}
+
+// handleSQSEvent processes all lifecycle messages of the batch and reports failed messages
+func handleSQSEvent(ctx context.Context, settings *settings, payload json.RawMessage) (*events.SQSEventResponse, error) {
+	var sqsEvent events.SQSEvent
+	if err := json.Unmarshal(payload, &sqsEvent); err != nil {
+		return nil, err
+	}
+
+	var messages []*lifecycleMessage
+	for _, record := range sqsEvent.Records {
+		fmt.Println("SQS Message", record.MessageId, record.Body)
+		messages = append(messages, newLifecycleMessage(record.MessageId, record.Body))
+	}
+
+	errs := processLifecycleMessages(ctx, settings, messages)
+
+	response := &events.SQSEventResponse{}
+	for _, message := range messages {
+		if errs[message.id] != nil {
+			response.BatchItemFailures = append(response.BatchItemFailures, events.SQSBatchItemFailure{
+				ItemIdentifier: message.id,
+			})
+		}
+	}
+
+	return response, nil
+}
+
+func newLifecycleMessage(id string, body string) *lifecycleMessage {
+	event, err := parseLifecycleMessage(body)
+	return &lifecycleMessage{
+		id:       id,
+		event:    event,
+		parseErr: err,
+	}
+}
+
+// processLifecycleMessages updates records of all messages in one batch and returns errors by message ID
+func processLifecycleMessages(ctx context.Context, settings *settings, messages []*lifecycleMessage) map[string]error {
+	errs := map[string]error{}
+	var pending []*lifecycleMessage
+	for _, message := range messages {
+		switch {
+		case message.parseErr != nil:
+			fmt.Println("Failed parsing message", message.id, message.parseErr)
+			errs[message.id] = message.parseErr
+		case message.event.Event == testNotificationEvent:
+			fmt.Println("Received test notification, skipping", message.id)
+		case !isSupportedTransition(message.event):
+			fmt.Println("The message does not contain supported LifecycleTransition, skipping", message.id)
+		default:
+			pending = append(pending, message)
+		}
+	}
+
+	if len(pending) == 0 {
+		return errs
+	}
+
+	processor := newProcessor(ctx, settings)
+	batch := processor.asgRoute53.NewChangeBatch()
+	for _, message := range pending {
+		message.failurePolicy, _ = settings.failurePolicyFor(message.event.LifecycleTransition, nil)
+		if err := processor.addChanges(ctx, batch, message); err != nil {
+			batch.Discard(message.id)
+			errs[message.id] = err
+		}
+	}
+
+	changeIDs, submitErrs := batch.Submit()
+	for id, err := range submitErrs {
+		if errs[id] == nil {
+			errs[id] = err
+		}
+	}
+
+	if settings.waitForInSync {
+		processor.waitForChanges(ctx, pending, changeIDs, errs)
+	}
+
+	var draining []*lifecycleMessage
+	for _, message := range pending {
+		if settings.terminationDrainMargin != nil && errs[message.id] == nil &&
+			message.event.LifecycleTransition == "autoscaling:EC2_INSTANCE_TERMINATING" {
+			draining = append(draining, message)
+			continue
+		}
+		processor.completeMessage(message, errs)
+	}
+
+	if len(draining) == 0 {
+		return errs
+	}
+
+	// Instances that resolvers may still return must not terminate, so a cut short drain leaves the
+	// actions open for the heartbeat timeout of the hook
+	if err := processor.drain(ctx, draining, batch, errs); err != nil {
+		fmt.Println("Drain was cut short, leaving lifecycle actions open until the heartbeat timeout: ", err)
+		for _, message := range draining {
+			if errs[message.id] == nil {
+				errs[message.id] = fmt.Errorf("drain was cut short: %w", err)
+			}
+		}
+		return errs
+	}
+
+	for _, message := range draining {
+		if errs[message.id] == nil {
+			processor.completeMessage(message, errs)
+		}
+	}
+
+	return errs
 }
 
 func main() {
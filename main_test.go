@@ -0,0 +1,630 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/vroad/asg-route53/asgroute53"
+)
+
+const (
+	launching   = "autoscaling:EC2_INSTANCE_LAUNCHING"
+	terminating = "autoscaling:EC2_INSTANCE_TERMINATING"
+)
+
+// newTestProcessor creates a processor with mocked clients. Instances without zone tags make no
+// Route 53 calls, so no Route 53 client is needed.
+func newTestProcessor(ec2Client *mockedEC2, asgClient *mockedAutoScaling, s *settings) *lifecycleProcessor {
+	return &lifecycleProcessor{
+		ec2Client:         ec2Client,
+		asgClient:         asgClient,
+		asgRoute53:        asgroute53.New(nil),
+		zoneConfigLoader:  asgroute53.NewZoneConfigLoader(nil),
+		settings:          s,
+		groupTags:         map[string][]*ec2.Tag{},
+		pollInterval:      time.Millisecond,
+		heartbeatInterval: asgroute53.HeartbeatInterval,
+	}
+}
+
+// withRoute53 makes the processor load zone configs and change records with the mocked client
+func withRoute53(p *lifecycleProcessor, route53Client *mockedRoute53) *lifecycleProcessor {
+	p.asgRoute53 = asgroute53.New(route53Client)
+	p.zoneConfigLoader = asgroute53.NewZoneConfigLoader(route53Client)
+	return p
+}
+
+// useProcessor makes the handlers use the processor for the rest of the test and returns a
+// pointer to the number of processors created
+func useProcessor(t *testing.T, processor *lifecycleProcessor) *int {
+	created := 0
+	original := newProcessor
+	newProcessor = func(ctx context.Context, settings *settings) *lifecycleProcessor {
+		created++
+		return processor
+	}
+	t.Cleanup(func() {
+		newProcessor = original
+	})
+
+	return &created
+}
+
+func newTestSettings(launchFailurePolicy failurePolicy) *settings {
+	return &settings{
+		launchFailurePolicy:    launchFailurePolicy,
+		terminateFailurePolicy: failurePolicyContinue,
+		publicIPTimeout:        time.Second,
+	}
+}
+
+func newTestInstances(instanceIDs ...string) map[string][]*ec2.Instance {
+	instances := map[string][]*ec2.Instance{}
+	for _, instanceID := range instanceIDs {
+		instances[instanceID] = []*ec2.Instance{
+			{
+				InstanceId:       aws.String(instanceID),
+				PrivateIpAddress: aws.String("10.0.0.1"),
+			},
+		}
+	}
+
+	return instances
+}
+
+func newTestEvent(instanceID string, transition string) *asgLifecycleEventDetail {
+	return &asgLifecycleEventDetail{
+		LifecycleActionToken: "token-" + instanceID,
+		AutoScalingGroupName: "asg",
+		LifecycleHookName:    "hook",
+		EC2InstanceID:        instanceID,
+		LifecycleTransition:  transition,
+	}
+}
+
+func marshal(t *testing.T, value interface{}) []byte {
+	data, err := json.Marshal(value)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+
+	return data
+}
+
+func Test_lifecycleProcessor_complete(t *testing.T) {
+	processErr := errors.New("processError")
+	completeErr := errors.New("completeError")
+	tests := []struct {
+		name        string
+		err         error
+		policy      failurePolicy
+		completeErr error
+		wantResult  string
+		wantErr     bool
+	}{
+		{
+			name:       "success",
+			err:        nil,
+			policy:     failurePolicyRetry,
+			wantResult: "CONTINUE",
+			wantErr:    false,
+		},
+		{
+			name:       "abandon",
+			err:        processErr,
+			policy:     failurePolicyAbandon,
+			wantResult: "ABANDON",
+			wantErr:    false,
+		},
+		{
+			name:       "continue",
+			err:        processErr,
+			policy:     failurePolicyContinue,
+			wantResult: "CONTINUE",
+			wantErr:    false,
+		},
+		{
+			name:       "retry",
+			err:        processErr,
+			policy:     failurePolicyRetry,
+			wantResult: "",
+			wantErr:    true,
+		},
+		{
+			name:        "complete-error",
+			err:         nil,
+			policy:      failurePolicyAbandon,
+			completeErr: completeErr,
+			wantResult:  "",
+			wantErr:     true,
+		},
+		{
+			name:        "abandon-complete-error",
+			err:         processErr,
+			policy:      failurePolicyAbandon,
+			completeErr: completeErr,
+			wantResult:  "",
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			asgClient := &mockedAutoScaling{completeLifecycleActionError: tt.completeErr}
+			p := newTestProcessor(&mockedEC2{}, asgClient, newTestSettings(failurePolicyAbandon))
+			message := &lifecycleMessage{
+				id:            "m1",
+				event:         newTestEvent("i-1", launching),
+				failurePolicy: tt.policy,
+			}
+
+			err := p.complete(message, tt.err)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("lifecycleProcessor.complete() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := asgClient.completedResults["i-1"]; got != tt.wantResult {
+				t.Errorf("lifecycleProcessor.complete() result = %v, want %v", got, tt.wantResult)
+			}
+			// Both the processing and the completion error are reported
+			if tt.err != nil && tt.wantErr && !errors.Is(err, tt.err) {
+				t.Errorf("lifecycleProcessor.complete() error = %v, want %v", err, tt.err)
+			}
+			if tt.completeErr != nil && !strings.Contains(err.Error(), tt.completeErr.Error()) {
+				t.Errorf("lifecycleProcessor.complete() error = %v, want %v", err, tt.completeErr)
+			}
+		})
+	}
+}
+
+func Test_handleSNSEvent(t *testing.T) {
+	newSNSEvent := func(messages map[string]interface{}) events.SNSEvent {
+		var snsEvent events.SNSEvent
+		for _, id := range []string{"m1", "m2"} {
+			if message, ok := messages[id]; ok {
+				snsEvent.Records = append(snsEvent.Records, events.SNSEventRecord{
+					SNS: events.SNSEntity{MessageID: id, Message: string(marshal(t, message))},
+				})
+			}
+		}
+		return snsEvent
+	}
+	tests := []struct {
+		name           string
+		event          events.SNSEvent
+		wantProcessors int
+		wantResults    map[string]string
+		wantErrIDs     []string
+	}{
+		{
+			name:           "no-records",
+			event:          events.SNSEvent{},
+			wantProcessors: 0,
+			wantResults:    map[string]string{},
+		},
+		{
+			name: "test-notification",
+			event: newSNSEvent(map[string]interface{}{
+				"m1": map[string]string{"Event": testNotificationEvent, "AutoScalingGroupName": "asg"},
+			}),
+			wantProcessors: 0,
+			wantResults:    map[string]string{},
+		},
+		{
+			name: "per-message-errors",
+			event: newSNSEvent(map[string]interface{}{
+				"m1": newTestEvent("i-1", launching),
+				"m2": newTestEvent("i-missing", launching),
+			}),
+			wantProcessors: 1,
+			wantResults:    map[string]string{"i-1": "CONTINUE"},
+			wantErrIDs:     []string{"m2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			asgClient := &mockedAutoScaling{completedResults: map[string]string{}}
+			s := newTestSettings(failurePolicyRetry)
+			created := useProcessor(t, newTestProcessor(&mockedEC2{instances: newTestInstances("i-1")}, asgClient, s))
+
+			err := handleSNSEvent(context.Background(), s, marshal(t, tt.event))
+			if (err != nil) != (len(tt.wantErrIDs) > 0) {
+				t.Fatalf("handleSNSEvent() error = %v, want errors of %v", err, tt.wantErrIDs)
+			}
+			for _, id := range tt.wantErrIDs {
+				if !strings.Contains(err.Error(), id+":") {
+					t.Errorf("handleSNSEvent() error = %v, want error of %v", err, id)
+				}
+			}
+			if err != nil && strings.Contains(err.Error(), "m1:") {
+				t.Errorf("handleSNSEvent() error = %v, want no error of m1", err)
+			}
+			if *created != tt.wantProcessors {
+				t.Errorf("handleSNSEvent() processors = %v, want %v", *created, tt.wantProcessors)
+			}
+			if !equalResults(asgClient.completedResults, tt.wantResults) {
+				t.Errorf("handleSNSEvent() results = %v, want %v", asgClient.completedResults, tt.wantResults)
+			}
+		})
+	}
+}
+
+func Test_handleCloudWatchEvent(t *testing.T) {
+	newCloudWatchEvent := func(detailType string, detail *asgLifecycleEventDetail) events.CloudWatchEvent {
+		return events.CloudWatchEvent{
+			ID:         "e1",
+			DetailType: detailType,
+			Source:     "aws.autoscaling",
+			Detail:     marshal(t, detail),
+		}
+	}
+	tests := []struct {
+		name           string
+		event          events.CloudWatchEvent
+		policy         failurePolicy
+		wantProcessors int
+		wantResults    map[string]string
+		wantErr        bool
+	}{
+		{
+			name:           "not-lifecycle-action",
+			event:          newCloudWatchEvent("EC2 Instance Launch Successful", newTestEvent("i-1", launching)),
+			policy:         failurePolicyAbandon,
+			wantProcessors: 0,
+			wantResults:    map[string]string{},
+			wantErr:        false,
+		},
+		{
+			name:           "launch",
+			event:          newCloudWatchEvent(launchLifecycleActionDetailType, newTestEvent("i-1", launching)),
+			policy:         failurePolicyAbandon,
+			wantProcessors: 1,
+			wantResults:    map[string]string{"i-1": "CONTINUE"},
+			wantErr:        false,
+		},
+		{
+			name:           "terminate",
+			event:          newCloudWatchEvent(terminateLifecycleActionDetailType, newTestEvent("i-1", terminating)),
+			policy:         failurePolicyAbandon,
+			wantProcessors: 1,
+			wantResults:    map[string]string{"i-1": "CONTINUE"},
+			wantErr:        false,
+		},
+		{
+			name:           "launch-failed-abandon",
+			event:          newCloudWatchEvent(launchLifecycleActionDetailType, newTestEvent("i-missing", launching)),
+			policy:         failurePolicyAbandon,
+			wantProcessors: 1,
+			wantResults:    map[string]string{"i-missing": "ABANDON"},
+			wantErr:        false,
+		},
+		{
+			name:           "launch-failed-retry",
+			event:          newCloudWatchEvent(launchLifecycleActionDetailType, newTestEvent("i-missing", launching)),
+			policy:         failurePolicyRetry,
+			wantProcessors: 1,
+			wantResults:    map[string]string{},
+			wantErr:        true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			asgClient := &mockedAutoScaling{completedResults: map[string]string{}}
+			s := newTestSettings(tt.policy)
+			created := useProcessor(t, newTestProcessor(&mockedEC2{instances: newTestInstances("i-1")}, asgClient, s))
+
+			err := handleCloudWatchEvent(context.Background(), s, marshal(t, tt.event))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("handleCloudWatchEvent() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if *created != tt.wantProcessors {
+				t.Errorf("handleCloudWatchEvent() processors = %v, want %v", *created, tt.wantProcessors)
+			}
+			if !equalResults(asgClient.completedResults, tt.wantResults) {
+				t.Errorf("handleCloudWatchEvent() results = %v, want %v", asgClient.completedResults, tt.wantResults)
+			}
+		})
+	}
+}
+
+func Test_handleSQSEvent(t *testing.T) {
+	newRecord := func(id string, body string) events.SQSMessage {
+		return events.SQSMessage{MessageId: id, Body: body, EventSource: "aws:sqs"}
+	}
+	sqsEvent := events.SQSEvent{
+		Records: []events.SQSMessage{
+			newRecord("m1", string(marshal(t, newTestEvent("i-1", launching)))),
+			newRecord("m2", string(marshal(t, events.SNSEntity{
+				Type:    "Notification",
+				Message: string(marshal(t, newTestEvent("i-2", launching))),
+			}))),
+			newRecord("m3", string(marshal(t, events.CloudWatchEvent{
+				DetailType: launchLifecycleActionDetailType,
+				Source:     "aws.autoscaling",
+				Detail:     marshal(t, newTestEvent("i-3", launching)),
+			}))),
+			newRecord("m4", string(marshal(t, newTestEvent("i-missing", launching)))),
+			newRecord("m5", "not JSON"),
+		},
+	}
+
+	asgClient := &mockedAutoScaling{completedResults: map[string]string{}}
+	s := newTestSettings(failurePolicyRetry)
+	useProcessor(t, newTestProcessor(&mockedEC2{instances: newTestInstances("i-1", "i-2", "i-3")}, asgClient, s))
+
+	response, err := handleSQSEvent(context.Background(), s, marshal(t, sqsEvent))
+	if err != nil {
+		t.Fatalf("handleSQSEvent() error = %v", err)
+	}
+
+	var failed []string
+	for _, failure := range response.BatchItemFailures {
+		failed = append(failed, failure.ItemIdentifier)
+	}
+	if !reflect.DeepEqual(failed, []string{"m4", "m5"}) {
+		t.Errorf("handleSQSEvent() failures = %v, want [m4 m5]", failed)
+	}
+	wantResults := map[string]string{"i-1": "CONTINUE", "i-2": "CONTINUE", "i-3": "CONTINUE"}
+	if !equalResults(asgClient.completedResults, wantResults) {
+		t.Errorf("handleSQSEvent() results = %v, want %v", asgClient.completedResults, wantResults)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	tests := []struct {
+		name         string
+		payload      interface{}
+		wantResponse bool
+		wantResults  map[string]string
+	}{
+		{
+			name: "sqs",
+			payload: events.SQSEvent{
+				Records: []events.SQSMessage{
+					{MessageId: "m1", Body: string(marshal(t, newTestEvent("i-1", launching))), EventSource: "aws:sqs"},
+				},
+			},
+			wantResponse: true,
+			wantResults:  map[string]string{"i-1": "CONTINUE"},
+		},
+		{
+			name: "sns",
+			payload: events.SNSEvent{
+				Records: []events.SNSEventRecord{
+					{
+						EventSource: "aws:sns",
+						SNS:         events.SNSEntity{MessageID: "m1", Message: string(marshal(t, newTestEvent("i-2", launching)))},
+					},
+				},
+			},
+			wantResponse: false,
+			wantResults:  map[string]string{"i-2": "CONTINUE"},
+		},
+		{
+			name: "eventbridge",
+			payload: events.CloudWatchEvent{
+				ID:         "e1",
+				DetailType: terminateLifecycleActionDetailType,
+				Source:     "aws.autoscaling",
+				Detail:     marshal(t, newTestEvent("i-3", terminating)),
+			},
+			wantResponse: false,
+			wantResults:  map[string]string{"i-3": "CONTINUE"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			asgClient := &mockedAutoScaling{completedResults: map[string]string{}}
+			ec2Client := &mockedEC2{instances: newTestInstances("i-1", "i-2", "i-3")}
+			useProcessor(t, newTestProcessor(ec2Client, asgClient, newTestSettings(failurePolicyAbandon)))
+
+			response, err := Handler(context.Background(), marshal(t, tt.payload))
+			if err != nil {
+				t.Fatalf("Handler() error = %v", err)
+			}
+			if (response != nil) != tt.wantResponse {
+				t.Errorf("Handler() response = %v, wantResponse %v", response, tt.wantResponse)
+			}
+			if !equalResults(asgClient.completedResults, tt.wantResults) {
+				t.Errorf("Handler() results = %v, want %v", asgClient.completedResults, tt.wantResults)
+			}
+		})
+	}
+}
+
+func Test_lifecycleProcessor_addChanges_metadata(t *testing.T) {
+	tests := []struct {
+		name      string
+		metadata  string
+		wantZones []string
+	}{
+		{
+			name:      "no-metadata",
+			metadata:  "",
+			wantZones: []string{"TAGGED"},
+		},
+		{
+			name:      "other-consumer",
+			metadata:  `{"team": "x"}`,
+			wantZones: []string{"TAGGED"},
+		},
+		{
+			name:      "merge",
+			metadata:  `{"mode": "merge", "zones": [{"hosted-zone-id": "META", "dns-records": ["meta.example.com"]}]}`,
+			wantZones: []string{"META", "TAGGED"},
+		},
+		{
+			name:      "replace",
+			metadata:  `{"mode": "replace", "zones": [{"hosted-zone-id": "META", "dns-records": ["meta.example.com"]}]}`,
+			wantZones: []string{"META"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instances := newTestInstances("i-1")
+			instances["i-1"][0].Tags = []*ec2.Tag{
+				{Key: aws.String("asg-route53-lambda:private-hosted-zone-id"), Value: aws.String("TAGGED")},
+				{Key: aws.String("asg-route53-lambda:private-dns-records"), Value: aws.String("tagged.example.com")},
+			}
+			route53Client := &mockedRoute53{hostedZoneNames: map[string]string{
+				"TAGGED": "example.com.",
+				"META":   "example.com.",
+			}}
+			p := withRoute53(newTestProcessor(&mockedEC2{instances: instances}, &mockedAutoScaling{}, newTestSettings(failurePolicyAbandon)), route53Client)
+			batch := p.asgRoute53.NewChangeBatch()
+			event := newTestEvent("i-1", launching)
+			event.NotificationMetadata = tt.metadata
+
+			if err := p.addChanges(context.Background(), batch, &lifecycleMessage{id: "m1", event: event}); err != nil {
+				t.Fatalf("lifecycleProcessor.addChanges() error = %v", err)
+			}
+			if _, errs := batch.Submit(); errs["m1"] != nil {
+				t.Fatalf("ChangeBatch.Submit() error = %v", errs["m1"])
+			}
+
+			var zones []string
+			for _, input := range route53Client.changeResourceRecordSetsInputs {
+				zones = append(zones, aws.StringValue(input.HostedZoneId))
+			}
+			sort.Strings(zones)
+			if !reflect.DeepEqual(zones, tt.wantZones) {
+				t.Errorf("lifecycleProcessor.addChanges() changed zones = %v, want %v", zones, tt.wantZones)
+			}
+		})
+	}
+}
+
+func Test_processLifecycleMessages_drain(t *testing.T) {
+	tests := []struct {
+		name            string
+		timeout         time.Duration
+		heartbeatErrors map[string]error
+		wantResults     map[string]string
+		wantErrIDs      []string
+	}{
+		{
+			name:        "completed",
+			timeout:     time.Minute,
+			wantResults: map[string]string{"i-1": "CONTINUE", "i-2": "CONTINUE"},
+		},
+		{
+			// The deadline leaves no time to drain, so the heartbeat timeout completes the actions
+			name:        "cut-short",
+			timeout:     asgroute53.DeadlineMargin,
+			wantResults: map[string]string{},
+			wantErrIDs:  []string{"m1", "m2"},
+		},
+		{
+			name:            "heartbeat-error",
+			timeout:         time.Minute,
+			heartbeatErrors: map[string]error{"i-2": errors.New("heartbeatError")},
+			wantResults:     map[string]string{"i-1": "CONTINUE"},
+			wantErrIDs:      []string{"m2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			asgClient := &mockedAutoScaling{
+				completedResults: map[string]string{},
+				heartbeatErrors:  tt.heartbeatErrors,
+			}
+			s := newTestSettings(failurePolicyAbandon)
+			margin := 50 * time.Millisecond
+			s.terminationDrainMargin = &margin
+			p := newTestProcessor(&mockedEC2{instances: newTestInstances("i-1", "i-2")}, asgClient, s)
+			p.heartbeatInterval = 10 * time.Millisecond
+			useProcessor(t, p)
+
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+			errs := processLifecycleMessages(ctx, s, []*lifecycleMessage{
+				{id: "m1", event: newTestEvent("i-1", terminating)},
+				{id: "m2", event: newTestEvent("i-2", terminating)},
+			})
+			var errIDs []string
+			for _, id := range []string{"m1", "m2"} {
+				if errs[id] != nil {
+					errIDs = append(errIDs, id)
+				}
+			}
+			if !reflect.DeepEqual(errIDs, tt.wantErrIDs) {
+				t.Errorf("processLifecycleMessages() errors = %v, want errors of %v", errs, tt.wantErrIDs)
+			}
+			if !equalResults(asgClient.completedResults, tt.wantResults) {
+				t.Errorf("processLifecycleMessages() results = %v, want %v", asgClient.completedResults, tt.wantResults)
+			}
+		})
+	}
+}
+
+func Test_lifecycleProcessor_waitForPublicIP(t *testing.T) {
+	withoutPublicIP := &ec2.Instance{InstanceId: aws.String("i-1")}
+	withPublicIP := &ec2.Instance{InstanceId: aws.String("i-1"), PublicIpAddress: aws.String("203.0.113.1")}
+	withElasticIP := &ec2.Instance{InstanceId: aws.String("i-1"), PublicIpAddress: aws.String("203.0.113.2")}
+	tests := []struct {
+		name      string
+		instances []*ec2.Instance
+		publicIP  string
+		timeout   time.Duration
+		want      *string
+		wantErr   bool
+	}{
+		{
+			name:      "associated",
+			instances: []*ec2.Instance{withoutPublicIP, withPublicIP},
+			timeout:   time.Second,
+			want:      aws.String("203.0.113.1"),
+			wantErr:   false,
+		},
+		{
+			name:      "elastic-ip",
+			instances: []*ec2.Instance{withPublicIP, withElasticIP},
+			publicIP:  "203.0.113.2",
+			timeout:   time.Second,
+			want:      aws.String("203.0.113.2"),
+			wantErr:   false,
+		},
+		{
+			name:      "timeout",
+			instances: []*ec2.Instance{withoutPublicIP},
+			timeout:   20 * time.Millisecond,
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ec2Client := &mockedEC2{instances: map[string][]*ec2.Instance{"i-1": tt.instances}}
+			s := newTestSettings(failurePolicyAbandon)
+			s.publicIPTimeout = tt.timeout
+			p := newTestProcessor(ec2Client, &mockedAutoScaling{}, s)
+
+			got, err := p.waitForPublicIP(context.Background(), newTestEvent("i-1", launching), tt.publicIP)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("lifecycleProcessor.waitForPublicIP() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && aws.StringValue(got.PublicIpAddress) != aws.StringValue(tt.want) {
+				t.Errorf("lifecycleProcessor.waitForPublicIP() = %v, want %v", aws.StringValue(got.PublicIpAddress), aws.StringValue(tt.want))
+			}
+		})
+	}
+}
+
+func equalResults(a map[string]string, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, value := range a {
+		if b[key] != value {
+			return false
+		}
+	}
+
+	return true
+}
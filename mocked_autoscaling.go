@@ -0,0 +1,62 @@
+package main
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/autoscaling/autoscalingiface"
+)
+
+type mockedAutoScaling struct {
+	autoscalingiface.AutoScalingAPI
+	groupTags []*autoscaling.TagDescription
+	// groupDeleted makes DescribeAutoScalingGroups find no group
+	groupDeleted                   bool
+	describeAutoScalingGroupsError error
+	describeAutoScalingGroupsCalls int
+	completeLifecycleActionError   error
+	// completedResults holds the lifecycle action result of each completed instance
+	completedResults map[string]string
+	heartbeats       []string
+	// heartbeatErrors fails the heartbeats of the instances
+	heartbeatErrors map[string]error
+}
+
+func (m *mockedAutoScaling) DescribeAutoScalingGroups(input *autoscaling.DescribeAutoScalingGroupsInput) (*autoscaling.DescribeAutoScalingGroupsOutput, error) {
+	m.describeAutoScalingGroupsCalls++
+	if m.describeAutoScalingGroupsError != nil {
+		return nil, m.describeAutoScalingGroupsError
+	}
+	if m.groupDeleted {
+		return &autoscaling.DescribeAutoScalingGroupsOutput{}, nil
+	}
+
+	return &autoscaling.DescribeAutoScalingGroupsOutput{
+		AutoScalingGroups: []*autoscaling.Group{
+			{
+				AutoScalingGroupName: input.AutoScalingGroupNames[0],
+				Tags:                 m.groupTags,
+			},
+		},
+	}, nil
+}
+
+func (m *mockedAutoScaling) CompleteLifecycleAction(input *autoscaling.CompleteLifecycleActionInput) (*autoscaling.CompleteLifecycleActionOutput, error) {
+	if m.completeLifecycleActionError != nil {
+		return nil, m.completeLifecycleActionError
+	}
+
+	if m.completedResults == nil {
+		m.completedResults = map[string]string{}
+	}
+	m.completedResults[aws.StringValue(input.InstanceId)] = aws.StringValue(input.LifecycleActionResult)
+	return &autoscaling.CompleteLifecycleActionOutput{}, nil
+}
+
+func (m *mockedAutoScaling) RecordLifecycleActionHeartbeat(input *autoscaling.RecordLifecycleActionHeartbeatInput) (*autoscaling.RecordLifecycleActionHeartbeatOutput, error) {
+	if err := m.heartbeatErrors[aws.StringValue(input.InstanceId)]; err != nil {
+		return nil, err
+	}
+
+	m.heartbeats = append(m.heartbeats, aws.StringValue(input.InstanceId))
+	return &autoscaling.RecordLifecycleActionHeartbeatOutput{}, nil
+}
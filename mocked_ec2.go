@@ -0,0 +1,74 @@
+package main
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+)
+
+type mockedEC2 struct {
+	ec2iface.EC2API
+	// instances holds the states returned for each instance ID in turn, the last one repeatedly
+	instances              map[string][]*ec2.Instance
+	describeInstancesError error
+	describeInstancesCalls int
+	// addresses is the Elastic IP pool returned by DescribeAddresses
+	addresses                   []*ec2.Address
+	associateAddressErrors      map[string]error
+	associatedAllocationIDs     []string
+	disassociateAddressError    error
+	disassociatedAssociationIDs []string
+}
+
+func (m *mockedEC2) DescribeInstances(input *ec2.DescribeInstancesInput) (*ec2.DescribeInstancesOutput, error) {
+	m.describeInstancesCalls++
+	if m.describeInstancesError != nil {
+		return nil, m.describeInstancesError
+	}
+
+	instanceID := aws.StringValue(input.InstanceIds[0])
+	states := m.instances[instanceID]
+	if len(states) == 0 {
+		return nil, awserr.New("InvalidInstanceID.NotFound", "instance not found: "+instanceID, nil)
+	}
+	if len(states) > 1 {
+		m.instances[instanceID] = states[1:]
+	}
+
+	return &ec2.DescribeInstancesOutput{
+		Reservations: []*ec2.Reservation{
+			{
+				Instances: []*ec2.Instance{states[0]},
+			},
+		},
+	}, nil
+}
+
+func (m *mockedEC2) DescribeAddresses(input *ec2.DescribeAddressesInput) (*ec2.DescribeAddressesOutput, error) {
+	return &ec2.DescribeAddressesOutput{
+		Addresses: m.addresses,
+	}, nil
+}
+
+// AssociateAddress fails with the error configured for the allocation ID, if any
+func (m *mockedEC2) AssociateAddress(input *ec2.AssociateAddressInput) (*ec2.AssociateAddressOutput, error) {
+	allocationID := aws.StringValue(input.AllocationId)
+	if err := m.associateAddressErrors[allocationID]; err != nil {
+		return nil, err
+	}
+
+	m.associatedAllocationIDs = append(m.associatedAllocationIDs, allocationID)
+	return &ec2.AssociateAddressOutput{
+		AssociationId: aws.String("eipassoc-" + allocationID),
+	}, nil
+}
+
+func (m *mockedEC2) DisassociateAddress(input *ec2.DisassociateAddressInput) (*ec2.DisassociateAddressOutput, error) {
+	if m.disassociateAddressError != nil {
+		return nil, m.disassociateAddressError
+	}
+
+	m.disassociatedAssociationIDs = append(m.disassociatedAssociationIDs, aws.StringValue(input.AssociationId))
+	return &ec2.DisassociateAddressOutput{}, nil
+}
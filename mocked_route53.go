@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/route53/route53iface"
+)
+
+type mockedRoute53 struct {
+	route53iface.Route53API
+	// hostedZoneNames holds the names of the hosted zones by ID
+	hostedZoneNames                map[string]string
+	changeResourceRecordSetsInputs []*route53.ChangeResourceRecordSetsInput
+}
+
+func (m *mockedRoute53) GetHostedZone(input *route53.GetHostedZoneInput) (*route53.GetHostedZoneOutput, error) {
+	name, ok := m.hostedZoneNames[aws.StringValue(input.Id)]
+	if !ok {
+		return nil, awserr.New(route53.ErrCodeNoSuchHostedZone, "no such hosted zone", nil)
+	}
+
+	return &route53.GetHostedZoneOutput{
+		HostedZone: &route53.HostedZone{
+			Id:     input.Id,
+			Name:   aws.String(name),
+			Config: &route53.HostedZoneConfig{PrivateZone: aws.Bool(false)},
+		},
+	}, nil
+}
+
+func (m *mockedRoute53) ChangeResourceRecordSets(input *route53.ChangeResourceRecordSetsInput) (*route53.ChangeResourceRecordSetsOutput, error) {
+	m.changeResourceRecordSetsInputs = append(m.changeResourceRecordSetsInputs, input)
+	return &route53.ChangeResourceRecordSetsOutput{
+		ChangeInfo: &route53.ChangeInfo{
+			Id:     aws.String(fmt.Sprintf("change-%d", len(m.changeResourceRecordSetsInputs))),
+			Status: aws.String(route53.ChangeStatusInsync),
+		},
+	}, nil
+}
+
+// changedValues returns the values of the record sets changed with the type, by record name
+func (m *mockedRoute53) changedValues(recordType string) map[string][]string {
+	values := map[string][]string{}
+	for _, input := range m.changeResourceRecordSetsInputs {
+		for _, change := range input.ChangeBatch.Changes {
+			if aws.StringValue(change.ResourceRecordSet.Type) != recordType {
+				continue
+			}
+			name := aws.StringValue(change.ResourceRecordSet.Name)
+			for _, resourceRecord := range change.ResourceRecordSet.ResourceRecords {
+				values[name] = append(values[name], aws.StringValue(resourceRecord.Value))
+			}
+		}
+	}
+
+	return values
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+const waitForInSyncEnv = "WAIT_FOR_INSYNC"
+const terminationDrainMarginEnv = "TERMINATION_DRAIN_MARGIN"
+const launchFailurePolicyEnv = "LAUNCH_FAILURE_POLICY"
+const terminateFailurePolicyEnv = "TERMINATE_FAILURE_POLICY"
+const publicIPTimeoutEnv = "PUBLIC_IP_TIMEOUT"
+
+// defaultPublicIPTimeout is how long launching instances are polled for a public IP by default
+const defaultPublicIPTimeout = time.Minute
+
+// Instance tags overriding the failure policies of the function
+const launchFailurePolicyKey = "asg-route53-lambda:launch-failure-policy"
+const terminateFailurePolicyKey = "asg-route53-lambda:terminate-failure-policy"
+
+// failurePolicy decides what happens to a lifecycle action when its records could not be updated
+type failurePolicy string
+
+const (
+	// failurePolicyAbandon completes the lifecycle action with ABANDON
+	failurePolicyAbandon failurePolicy = "ABANDON"
+	// failurePolicyContinue completes the lifecycle action with CONTINUE
+	failurePolicyContinue failurePolicy = "CONTINUE"
+	// failurePolicyRetry leaves the lifecycle action open and returns the error, so that the
+	// message is redelivered or the heartbeat timeout of the hook applies its default result
+	failurePolicyRetry failurePolicy = "RETRY"
+)
+
+// settings holds behaviour configured with environment variables of the function
+type settings struct {
+	waitForInSync bool
+	// terminationDrainMargin is added to the record TTL to get how long terminating instances are
+	// held after their records are removed. Draining is disabled when it is nil.
+	terminationDrainMargin *time.Duration
+	launchFailurePolicy    failurePolicy
+	terminateFailurePolicy failurePolicy
+	// publicIPTimeout is how long to wait for the public IPv4 address of a launching instance
+	// registered in a public zone
+	publicIPTimeout time.Duration
+}
+
+func loadSettings() (*settings, error) {
+	s := &settings{
+		launchFailurePolicy:    failurePolicyAbandon,
+		terminateFailurePolicy: failurePolicyContinue,
+		publicIPTimeout:        defaultPublicIPTimeout,
+	}
+
+	if value, ok := os.LookupEnv(waitForInSyncEnv); ok {
+		waitForInSync, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %s", waitForInSyncEnv, value)
+		}
+		s.waitForInSync = waitForInSync
+	}
+
+	if value, ok := os.LookupEnv(terminationDrainMarginEnv); ok {
+		margin, err := time.ParseDuration(value)
+		if err != nil || margin < 0 {
+			return nil, fmt.Errorf("invalid value for %s: %s", terminationDrainMarginEnv, value)
+		}
+		s.terminationDrainMargin = &margin
+	}
+
+	if value, ok := os.LookupEnv(launchFailurePolicyEnv); ok {
+		policy, err := parseFailurePolicy(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %w", launchFailurePolicyEnv, err)
+		}
+		s.launchFailurePolicy = policy
+	}
+
+	if value, ok := os.LookupEnv(terminateFailurePolicyEnv); ok {
+		policy, err := parseFailurePolicy(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %w", terminateFailurePolicyEnv, err)
+		}
+		s.terminateFailurePolicy = policy
+	}
+
+	if value, ok := os.LookupEnv(publicIPTimeoutEnv); ok {
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout < 0 {
+			return nil, fmt.Errorf("invalid value for %s: %s", publicIPTimeoutEnv, value)
+		}
+		s.publicIPTimeout = timeout
+	}
+
+	return s, nil
+}
+
+func parseFailurePolicy(value string) (failurePolicy, error) {
+	switch policy := failurePolicy(strings.ToUpper(value)); policy {
+	case failurePolicyAbandon, failurePolicyContinue, failurePolicyRetry:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unsupported failure policy: %s", value)
+	}
+}
+
+// failurePolicyFor returns the failure policy of the transition, preferring the instance tag over
+// the function setting
+func (s *settings) failurePolicyFor(transition string, tags []*ec2.Tag) (failurePolicy, error) {
+	policy, key := s.launchFailurePolicy, launchFailurePolicyKey
+	if transition == "autoscaling:EC2_INSTANCE_TERMINATING" {
+		policy, key = s.terminateFailurePolicy, terminateFailurePolicyKey
+	}
+
+	for _, tag := range tags {
+		if aws.StringValue(tag.Key) == key {
+			tagPolicy, err := parseFailurePolicy(aws.StringValue(tag.Value))
+			if err != nil {
+				return policy, fmt.Errorf("invalid value for %s: %w", key, err)
+			}
+			return tagPolicy, nil
+		}
+	}
+
+	return policy, nil
+}
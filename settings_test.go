@@ -0,0 +1,75 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+func Test_settings_failurePolicyFor(t *testing.T) {
+	s := &settings{
+		launchFailurePolicy:    failurePolicyAbandon,
+		terminateFailurePolicy: failurePolicyContinue,
+	}
+	tests := []struct {
+		name       string
+		transition string
+		tags       []*ec2.Tag
+		want       failurePolicy
+		wantErr    bool
+	}{
+		{
+			name:       "launch-default",
+			transition: "autoscaling:EC2_INSTANCE_LAUNCHING",
+			want:       failurePolicyAbandon,
+			wantErr:    false,
+		},
+		{
+			name:       "terminate-default",
+			transition: "autoscaling:EC2_INSTANCE_TERMINATING",
+			want:       failurePolicyContinue,
+			wantErr:    false,
+		},
+		{
+			name:       "launch-tag",
+			transition: "autoscaling:EC2_INSTANCE_LAUNCHING",
+			tags: []*ec2.Tag{
+				{Key: aws.String(launchFailurePolicyKey), Value: aws.String("retry")},
+				{Key: aws.String(terminateFailurePolicyKey), Value: aws.String("ABANDON")},
+			},
+			want:    failurePolicyRetry,
+			wantErr: false,
+		},
+		{
+			name:       "terminate-tag",
+			transition: "autoscaling:EC2_INSTANCE_TERMINATING",
+			tags: []*ec2.Tag{
+				{Key: aws.String(launchFailurePolicyKey), Value: aws.String("RETRY")},
+				{Key: aws.String(terminateFailurePolicyKey), Value: aws.String("ABANDON")},
+			},
+			want:    failurePolicyAbandon,
+			wantErr: false,
+		},
+		{
+			name:       "invalid-tag",
+			transition: "autoscaling:EC2_INSTANCE_LAUNCHING",
+			tags: []*ec2.Tag{
+				{Key: aws.String(launchFailurePolicyKey), Value: aws.String("ignore")},
+			},
+			want:    failurePolicyAbandon,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.failurePolicyFor(tt.transition, tt.tags)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("settings.failurePolicyFor() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("settings.failurePolicyFor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}